@@ -0,0 +1,383 @@
+package soscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Defs is a loaded def file, every config is assigned over a fresh copy of its variables.
+// The def file is parsed again for each config, importDirs are searched for the def files it imports
+type Defs struct {
+	name        string
+	source      []byte
+	importDirs  []string
+	diagnostics []*Diagnostic
+}
+
+// LoadDefs loads the def file from r, name is the file name in diagnostics and the base of relative imports.
+// Only the read error is returned, the errors of the def file are kept by defs and reported again by its configs
+func LoadDefs(name string, r io.Reader, importDirs ...string) (*Defs, error) {
+	source, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	defs := &Defs{name: name, source: source, importDirs: importDirs}
+	defs.diagnostics = defs.newParser().diagnostics
+	return defs, nil
+}
+
+// LoadDefsFile loads the def file of path
+func LoadDefsFile(path string, importDirs ...string) (*Defs, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadDefs(path, file, importDirs...)
+}
+
+// Err returns the errors of the def file, nil when there is none
+func (d *Defs) Err() error {
+	if !hasDiagnosticError(d.diagnostics) {
+		return nil
+	}
+	return newError(d.diagnostics, nil)
+}
+
+func (d *Defs) newParser() *parser {
+	defLexer := newLexer("ss", d.name, bytes.NewReader(d.source))
+	return newParser(defLexer, newLexer("ss", "", strings.NewReader("")), d.importDirs)
+}
+
+// WriteVars prints the declared variables with their values, defaults and descriptions, and the declared macros
+func (d *Defs) WriteVars(w io.Writer) {
+	writeVarDefs(d.newParser(), w)
+}
+
+// Config is the values of the variables of a def file, assigned by config layers over the defaults.
+// A later layer overrides the former ones. The errors of the def file and the layers are kept by config,
+// and returned with the errors of the sources by Compile and the other functions taking it. A config is not safe for concurrent use
+type Config struct {
+	parser *parser
+}
+
+// NewConfig returns the config with the default values only
+func (d *Defs) NewConfig() *Config {
+	parser := d.newParser()
+	parser.applyDefaults()
+	return &Config{parser: parser}
+}
+
+// LoadConfig loads the config file from r over the defaults of defs, the format of config is detected from name.
+// Only the read error is returned, see Config.Err for the errors of the def and config files
+func LoadConfig(defs *Defs, name string, r io.Reader) (*Config, error) {
+	c := defs.NewConfig()
+	if err := c.Load(name, r); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load assigns the config file from r, the format of config is detected from name. Only the read error is returned
+func (c *Config) Load(name string, r io.Reader) error {
+	source, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.layer(newConfigLexer(name, bytes.NewReader(source)))
+	return nil
+}
+
+// LoadFile assigns the config file of path, only the open and read errors are returned
+func (c *Config) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.Load(path, file)
+}
+
+// LoadEnv assigns the SSC_VAR_<name> variables of environ, which is in the form of os.Environ
+func (c *Config) LoadEnv(environ []string) {
+	c.layer(newEnvConfigLexer(environ, c.parser.varNameList))
+}
+
+// Set assigns the variables in the form of name=value, eg. platform=android
+func (c *Config) Set(assigns ...string) {
+	c.layer(newLexer("env", "-D", strings.NewReader(strings.Join(assigns, "\n"))))
+}
+
+func (c *Config) layer(lexer *lexer) {
+	c.parser.parseConfigLayer(lexer)
+	c.parser.applyDefaults()
+}
+
+// Err returns the errors of the def file and the config layers, nil when there is none
+func (c *Config) Err() error {
+	return c.result(len(c.parser.diagnostics))
+}
+
+// Check returns the errors of Err with the required variables that are not assigned by any config layer
+func (c *Config) Check() error {
+	from := len(c.parser.diagnostics)
+	c.parser.checkRequired()
+	err := c.result(from)
+	c.parser.diagnostics = c.parser.diagnostics[:from]
+	return err
+}
+
+// WriteTable prints the value of every variable, with where it is assigned
+func (c *Config) WriteTable(w io.Writer) {
+	writeEffectiveConfig(c.parser, w)
+}
+
+// result returns the error of the diagnostics found since from. The errors of the def file and the config layers are returned with them,
+// so that a config with errors never compiles without an error
+func (c *Config) result(from int) error {
+	diagnostics := c.since(from)
+	if !hasDiagnosticError(diagnostics) {
+		return nil
+	}
+	return newError(diagnostics, nil)
+}
+
+// since returns the diagnostics found since from, or all the diagnostics when the former ones have any error
+func (c *Config) since(from int) []*Diagnostic {
+	if hasDiagnosticError(c.parser.diagnostics[:from]) {
+		return c.parser.diagnostics
+	}
+	return c.parser.diagnostics[from:]
+}
+
+// run calls f with the diagnostics of config, the diagnostics found by f are returned and removed,
+// so that the config can be used again
+func (c *Config) run(checkRequired bool, f func() error) error {
+	from := len(c.parser.diagnostics)
+	if checkRequired {
+		c.parser.checkRequired()
+	}
+	err := f()
+	if diagnosticErr := c.result(from); diagnosticErr != nil && err == nil {
+		err = diagnosticErr
+	}
+	c.parser.diagnostics = c.parser.diagnostics[:from]
+	return err
+}
+
+// Options are the options of compiling, the zero value detects the comment style from the file names
+type Options struct {
+	// Name is the file name of the source read by Compile and Explain, used in diagnostics and to detect the comment style
+	Name string
+	// Comment is a file extension like py or the comment marks like "<!-- -->", the style is detected from the file name when it is empty
+	Comment string
+	// InPlace rewrites the default code and keeps the soscript tags
+	InPlace bool
+	// Stream reads the sources block by block with bounded memory, the output is incomplete when there is any error
+	Stream bool
+	// Include and Exclude are the glob patterns of the files under a directory, a pattern without "/" matches the base name
+	Include []string
+	Exclude []string
+	// Filter is the condition selecting the combinations of matrix and coverage, eg. platform != "h5"
+	Filter string
+	// Log receives the built combinations of matrix, ErrLog the failed combinations with their diagnostics, nil discards them
+	Log    io.Writer
+	ErrLog io.Writer
+}
+
+func optionsOf(opts *Options) *Options {
+	if opts == nil {
+		return &Options{}
+	}
+	return opts
+}
+
+// comment parses the comment style, nil is returned when the style is detected from the file name
+func (o *Options) comment() (*commentStyle, error) {
+	if o.Comment == "" {
+		return nil, nil
+	}
+	comment := parseCommentStyle(o.Comment)
+	if comment == nil {
+		return nil, fmt.Errorf("invalid comment %q, use a file extension like py or comment marks like \"<!-- -->\"", o.Comment)
+	}
+	return comment, nil
+}
+
+// Compile compiles the source from src to dst with the config, nothing is written to dst when the source has any error,
+// unless opts.Stream is set, which writes every block as soon as it is compiled
+func Compile(src io.Reader, dst io.Writer, cfg *Config, opts *Options) error {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return err
+	}
+	return cfg.run(true, func() error {
+		if opts.Stream {
+			writer := bufio.NewWriter(dst)
+			generator := newSourceGen(opts.InPlace, cfg.parser)
+			if _, err := generator.genStream(newStreamLexer(opts.Name, comment, src, writer)); err != nil {
+				return err
+			}
+			return writer.Flush()
+		}
+		source, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		output, _, _ := compileSource(cfg.parser, newSourceLexer(opts.Name, comment, bytes.NewReader(source)), opts.InPlace)
+		if cfg.parser.hasError() {
+			return nil
+		}
+		_, err = io.WriteString(dst, output)
+		return err
+	})
+}
+
+// CompileFile compiles the source file into the output file, the source file is compiled in place when they are the same.
+// The output file is not written when the source has any error
+func CompileFile(sourcePath string, outputPath string, cfg *Config, opts *Options) error {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return err
+	}
+	return cfg.run(true, func() error {
+		if opts.Stream {
+			_, _, _, err := compileStreamFile(cfg.parser, sourcePath, outputPath, opts.InPlace, comment)
+			return err
+		}
+		_, _, _, err := compileFile(cfg.parser, sourcePath, outputPath, opts.InPlace, comment)
+		return err
+	})
+}
+
+// CompileDir mirrors the source dir into the output dir, the files with soscript are compiled and other files are copied as they are.
+// The source dir is compiled in place when they are the same, the output dir under the source dir is skipped.
+// Nothing is written after a file with any error, or when config has any error. The files after it are still compiled for their errors
+func CompileDir(sourceDir string, outputDir string, cfg *Config, opts *Options) (*Summary, error) {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return nil, err
+	}
+	var summary *Summary
+	err = cfg.run(true, func() error {
+		files, err := loadSourceDir(sourceDir, outputDir, opts.Stream, comment, opts.Include, opts.Exclude)
+		if err != nil {
+			return err
+		}
+		summary, err = compileSourceFiles(cfg.parser, files, outputDir, opts.InPlace)
+		return err
+	})
+	return summary, err
+}
+
+// CompileFS compiles the files of fsys, the outputs and the files without soscript are given to write with their paths in fsys.
+// Nothing is written after a file with any error, or when config has any error. The files after it are still compiled for their errors
+func CompileFS(fsys fs.FS, cfg *Config, opts *Options, write func(name string, data []byte) error) (*Summary, error) {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return nil, err
+	}
+	var summary *Summary
+	err = cfg.run(true, func() error {
+		summary, err = compileFS(cfg.parser, fsys, comment, opts.InPlace, opts.Include, opts.Exclude, write)
+		return err
+	})
+	return summary, err
+}
+
+// Sources are the source files lexed once for matrix and coverage
+type Sources struct {
+	files []*sourceFile
+}
+
+// LoadSources loads the source file of path, or the files under it when it is a dir. The output dir under the source dir is skipped,
+// outputDir is empty when there is no output
+func LoadSources(path string, outputDir string, opts *Options) (*Sources, error) {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		files, err := loadSourceDir(path, outputDir, false, comment, opts.Include, opts.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		return &Sources{files: files}, nil
+	}
+	file, err := loadSourceFile(path, filepath.Base(path), info.Mode().Perm(), comment)
+	if err != nil {
+		return nil, err
+	}
+	// the output dir of each matrix combination is created by the dir entry
+	dir := &sourceFile{path: filepath.Dir(path), relPath: ".", isDir: true, mode: 0755}
+	return &Sources{files: []*sourceFile{dir, file}}, nil
+}
+
+// BuildMatrix compiles the sources once for every combination of the values of the variables that config does not assign,
+// into outputDir/<combination>. A failed combination does not stop the others, nothing is built when config has any error
+func BuildMatrix(cfg *Config, sources *Sources, outputDir string, opts *Options) (*MatrixSummary, error) {
+	opts = optionsOf(opts)
+	if err := cfg.Err(); err != nil {
+		return nil, err
+	}
+	var summary *MatrixSummary
+	err := cfg.run(false, func() error {
+		var err error
+		summary, err = buildMatrix(cfg.parser, sources.files, outputDir, opts.Filter, opts.Log, opts.ErrLog)
+		return err
+	})
+	return summary, err
+}
+
+// Coverage evaluates the sources with every combination of the values of the variables that config does not assign,
+// and reports the lines that never or always fire. A failed combination is not counted in the hits, and the report is
+// returned with an error when any combination fails. Nothing is evaluated when config has any error
+func Coverage(cfg *Config, sources *Sources, opts *Options) (*CoverageReport, error) {
+	opts = optionsOf(opts)
+	if err := cfg.Err(); err != nil {
+		return nil, err
+	}
+	var report *CoverageReport
+	err := cfg.run(false, func() error {
+		var err error
+		report, err = analyzeCoverage(cfg.parser, sources.files, opts.Filter, opts.ErrLog)
+		return err
+	})
+	return report, err
+}
+
+// Explain prints to w why the lines of the block at lineno of the source are or are not selected with the config.
+// The diagnostics of the def, config and source files are returned after the explanation, which may tell why they happen
+func Explain(w io.Writer, src io.Reader, lineno int, cfg *Config, opts *Options) error {
+	opts = optionsOf(opts)
+	comment, err := opts.comment()
+	if err != nil {
+		return err
+	}
+	from := len(cfg.parser.diagnostics)
+	defer func() {
+		cfg.parser.diagnostics = cfg.parser.diagnostics[:from]
+	}()
+	cfg.parser.checkRequired()
+	cfg.parser.parseSourceCode(newSourceLexer(opts.Name, comment, src))
+	if err := newExplainer(cfg.parser, w).explainLine(lineno); err != nil {
+		return newError(cfg.since(from), err)
+	}
+	return cfg.result(from)
+}
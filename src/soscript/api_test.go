@@ -0,0 +1,256 @@
+package soscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testDefs = `platform: {"pc", "android", "ios", "h5"}
+	desc "platform that the program run on"
+
+level: int[1..5]
+	default 3
+`
+
+const testSource = `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+// </soscript>
+`
+
+func loadTestConfig(t *testing.T, defs string, config string) *Config {
+	t.Helper()
+	d, err := LoadDefs("def.ss", strings.NewReader(defs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(d, "conf.ss", strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// diagnosticsOf returns the diagnostics of err in the form of file:line: message
+func diagnosticsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var compileErr *Error
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	var ret []string
+	for _, v := range compileErr.Diagnostics {
+		ret = append(ret, fmt.Sprintf("%s:%d: %s", v.File, v.Lineno, v.Message))
+	}
+	return ret
+}
+
+func checkDiagnostics(t *testing.T, err error, want []string) {
+	t.Helper()
+	got := diagnosticsOf(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("got diagnostics\n%s\nwant %d", strings.Join(got, "\n"), len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("diagnostic %d: got %q, want prefix %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	var out bytes.Buffer
+	if err := Compile(strings.NewReader(testSource), &out, cfg, &Options{Name: "src.java"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "int a = 1;\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+	// the config compiles again with another layer
+	cfg.Set("platform=ios")
+	out.Reset()
+	if err := Compile(strings.NewReader(testSource), &out, cfg, &Options{Name: "src.java"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "int a = 0;\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+// the errors of def, config and source files are returned together
+func TestCompileErrors(t *testing.T) {
+	defs := strings.Replace(testDefs, "default 3", "default 9", 1)
+	source := strings.Replace(testSource, `"android"`, `"andriod"`, 1)
+	d, err := LoadDefs("def.ss", strings.NewReader(defs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDiagnostics(t, d.Err(), []string{
+		"def.ss:5: value 9 of level is out of range",
+	})
+	cfg, err := LoadConfig(d, "conf.ss", strings.NewReader(`platform = "pcc"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDiagnostics(t, cfg.Err(), []string{
+		"def.ss:5: value 9 of level is out of range",
+		`conf.ss:1: value "pcc" is not declared for platform`,
+	})
+	var out bytes.Buffer
+	err = Compile(strings.NewReader(source), &out, cfg, &Options{Name: "src.java"})
+	checkDiagnostics(t, err, []string{
+		"def.ss:1: required variable platform",
+		"def.ss:5: value 9 of level is out of range",
+		`conf.ss:1: value "pcc" is not declared for platform`,
+		`src.java:5: value "andriod" is not declared for platform`,
+	})
+	if out.Len() != 0 {
+		t.Errorf("got output %q, want nothing", out.String())
+	}
+	// the diagnostics of the source are not kept by the config
+	checkDiagnostics(t, cfg.Err(), []string{
+		"def.ss:5: value 9 of level is out of range",
+		`conf.ss:1: value "pcc" is not declared for platform`,
+	})
+}
+
+func TestCompileInvalidComment(t *testing.T) {
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	err := Compile(strings.NewReader(testSource), &bytes.Buffer{}, cfg, &Options{Comment: "a b c"})
+	var compileErr *Error
+	if err == nil || errors.As(err, &compileErr) {
+		t.Errorf("got error %v, want the error of comment", err)
+	}
+}
+
+func TestCompileFile(t *testing.T) {
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "src.java")
+	outputPath := filepath.Join(dir, "out.java")
+	if err := os.WriteFile(sourcePath, []byte(testSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompileFile(sourcePath, outputPath, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := os.ReadFile(outputPath); string(output) != "int a = 1;\n" {
+		t.Errorf("got %q", output)
+	}
+
+	// the missing source is not a diagnostic
+	err := CompileFile(filepath.Join(dir, "missing.java"), outputPath, cfg, nil)
+	var compileErr *Error
+	if !errors.Is(err, fs.ErrNotExist) || errors.As(err, &compileErr) {
+		t.Errorf("got error %v, want the error of missing file", err)
+	}
+
+	// the output is not written when the source has any error
+	source := strings.Replace(testSource, `"android"`, `"andriod"`, 1)
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(outputPath)
+	for _, stream := range []bool{false, true} {
+		err := CompileFile(sourcePath, outputPath, cfg, &Options{Stream: stream})
+		checkDiagnostics(t, err, []string{
+			sourcePath + `:5: value "andriod" is not declared for platform`,
+		})
+		if _, err := os.Stat(outputPath); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("stream %v: output is written with error %v", stream, err)
+		}
+	}
+}
+
+// compileTestFS compiles fsys and returns the written files
+func compileTestFS(t *testing.T, fsys fs.FS, cfg *Config, opts *Options) (map[string]string, *Summary, error) {
+	t.Helper()
+	written := map[string]string{}
+	summary, err := CompileFS(fsys, cfg, opts, func(name string, data []byte) error {
+		written[name] = string(data)
+		return nil
+	})
+	return written, summary, err
+}
+
+func TestCompileFS(t *testing.T) {
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	fsys := fstest.MapFS{
+		"a.java":       {Data: []byte(testSource)},
+		"b.txt":        {Data: []byte("plain")},
+		"sub/c.java":   {Data: []byte(testSource)},
+		"skip/d.java":  {Data: []byte(testSource)},
+		"sub/e.tmp":    {Data: []byte("tmp")},
+		"sub/f.java":   {Data: []byte("no soscript")},
+		"skip/g.other": {Data: []byte("skipped")},
+	}
+	written, summary, err := compileTestFS(t, fsys, cfg, &Options{Exclude: []string{"skip", "*.tmp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.java":     "int a = 1;\n",
+		"b.txt":      "plain",
+		"sub/c.java": "int a = 1;\n",
+		"sub/f.java": "no soscript",
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("got written %v, want %v", written, want)
+	}
+	if summary.Files != 4 || summary.CompiledFiles != 2 || summary.CopiedFiles != 2 || summary.ChangedFiles != 2 {
+		t.Errorf("got summary %v", summary)
+	}
+}
+
+func TestCompileFSErrors(t *testing.T) {
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	bad := strings.Replace(testSource, `"android"`, `"andriod"`, 1)
+	fsys := fstest.MapFS{
+		"0.txt":  {Data: []byte("before")},
+		"a.java": {Data: []byte(bad)},
+		"b.txt":  {Data: []byte("after")},
+		"c.java": {Data: []byte(testSource)},
+		"d.java": {Data: []byte(strings.Replace(bad, "andriod", "iso", 1))},
+	}
+	written, _, err := compileTestFS(t, fsys, cfg, nil)
+	// the files after the error are compiled for their errors, but not written
+	checkDiagnostics(t, err, []string{
+		`a.java:5: value "andriod" is not declared for platform`,
+		`d.java:5: value "iso" is not declared for platform`,
+	})
+	if want := map[string]string{"0.txt": "before"}; !reflect.DeepEqual(written, want) {
+		t.Errorf("got written %v, want %v", written, want)
+	}
+
+	// nothing is written with the errors of config
+	cfg = loadTestConfig(t, testDefs, `platform = "pcc"`)
+	written, _, err = compileTestFS(t, fstest.MapFS{"b.txt": {Data: []byte("plain")}}, cfg, nil)
+	checkDiagnostics(t, err, []string{
+		`conf.ss:1: value "pcc" is not declared for platform`,
+		"def.ss:1: required variable platform",
+	})
+	if len(written) != 0 {
+		t.Errorf("got written %v, want nothing", written)
+	}
+
+	// the error of write stops the walk
+	writeErr := errors.New("disk full")
+	cfg = loadTestConfig(t, testDefs, `platform = "android"`)
+	_, err = CompileFS(fstest.MapFS{"a.java": {Data: []byte(testSource)}}, cfg, nil, func(string, []byte) error {
+		return writeErr
+	})
+	if !errors.Is(err, writeErr) {
+		t.Errorf("got error %v, want %v", err, writeErr)
+	}
+}
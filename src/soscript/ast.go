@@ -0,0 +1,172 @@
+package soscript
+
+import (
+	"strings"
+)
+
+// exprNode is a node of the condition expression tree
+type exprNode interface {
+	// exprToken is the token used to report errors of the node
+	exprToken() *token
+	String() string
+}
+
+// identExpr is a variable, eg. platform
+type identExpr struct {
+	token *token
+	name  string
+}
+
+// literalExpr is a constant value, eg. "1.0.1"
+type literalExpr struct {
+	token   *token
+	valType string
+	text    string
+}
+
+// binaryExpr is an operation with two operands, eg. version == "1.0.1"
+type binaryExpr struct {
+	token *token
+	op    int
+	left  exprNode
+	right exprNode
+}
+
+// unaryExpr is an operation with one operand, eg. !isDebug
+type unaryExpr struct {
+	token   *token
+	op      int
+	operand exprNode
+}
+
+// parenExpr is an expression in brackets
+type parenExpr struct {
+	token *token
+	inner exprNode
+}
+
+// inExpr tells whether the value is one of the list, eg. platform in ("android", "ios")
+type inExpr struct {
+	token *token // in or not in
+	not   bool
+	left  exprNode
+	list  []exprNode
+}
+
+// callExpr is a function call, eg. isMiniGame()
+type callExpr struct {
+	token *token
+	name  string
+	args  []exprNode
+}
+
+func (e *identExpr) exprToken() *token   { return e.token }
+func (e *literalExpr) exprToken() *token { return e.token }
+func (e *binaryExpr) exprToken() *token  { return e.token }
+func (e *unaryExpr) exprToken() *token   { return e.token }
+func (e *parenExpr) exprToken() *token   { return e.token }
+func (e *callExpr) exprToken() *token    { return e.token }
+func (e *inExpr) exprToken() *token      { return e.token }
+
+func (e *identExpr) String() string {
+	return e.name
+}
+
+func (e *literalExpr) String() string {
+	return e.text
+}
+
+func (e *binaryExpr) String() string {
+	return e.left.String() + " " + e.token.text + " " + e.right.String()
+}
+
+func (e *unaryExpr) String() string {
+	return e.token.text + e.operand.String()
+}
+
+func (e *parenExpr) String() string {
+	return "(" + e.inner.String() + ")"
+}
+
+func (e *inExpr) String() string {
+	list := make([]string, 0, len(e.list))
+	for _, v := range e.list {
+		list = append(list, v.String())
+	}
+	op := " in "
+	if e.not {
+		op = " not in "
+	}
+	return e.left.String() + op + "(" + strings.Join(list, ", ") + ")"
+}
+
+func (e *callExpr) String() string {
+	args := make([]string, 0, len(e.args))
+	for _, v := range e.args {
+		args = append(args, v.String())
+	}
+	return e.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// unparenExpr removes the brackets around the expression
+func unparenExpr(expr exprNode) exprNode {
+	for {
+		paren, ok := expr.(*parenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.inner
+	}
+}
+
+// walkExpr calls f with every node of the expression tree, parents before children
+func walkExpr(expr exprNode, f func(exprNode)) {
+	f(expr)
+	switch v := expr.(type) {
+	case *binaryExpr:
+		walkExpr(v.left, f)
+		walkExpr(v.right, f)
+	case *unaryExpr:
+		walkExpr(v.operand, f)
+	case *parenExpr:
+		walkExpr(v.inner, f)
+	case *callExpr:
+		for _, arg := range v.args {
+			walkExpr(arg, f)
+		}
+	case *inExpr:
+		walkExpr(v.left, f)
+		for _, item := range v.list {
+			walkExpr(item, f)
+		}
+	}
+}
+
+// substituteExpr copies the expression tree with the variables in vars replaced, the nodes not changed are shared
+func substituteExpr(expr exprNode, vars map[string]exprNode) exprNode {
+	switch v := expr.(type) {
+	case *identExpr:
+		if ret, ok := vars[v.name]; ok {
+			return ret
+		}
+	case *binaryExpr:
+		return &binaryExpr{token: v.token, op: v.op, left: substituteExpr(v.left, vars), right: substituteExpr(v.right, vars)}
+	case *unaryExpr:
+		return &unaryExpr{token: v.token, op: v.op, operand: substituteExpr(v.operand, vars)}
+	case *parenExpr:
+		return &parenExpr{token: v.token, inner: substituteExpr(v.inner, vars)}
+	case *callExpr:
+		args := make([]exprNode, 0, len(v.args))
+		for _, arg := range v.args {
+			args = append(args, substituteExpr(arg, vars))
+		}
+		return &callExpr{token: v.token, name: v.name, args: args}
+	case *inExpr:
+		list := make([]exprNode, 0, len(v.list))
+		for _, item := range v.list {
+			list = append(list, substituteExpr(item, vars))
+		}
+		return &inExpr{token: v.token, not: v.not, left: substituteExpr(v.left, vars), list: list}
+	}
+	return expr
+}
@@ -0,0 +1,135 @@
+package soscript
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// commentStyle is the comment syntax of a language, suffix is empty for line comments
+type commentStyle struct {
+	prefix string
+	suffix string
+}
+
+var (
+	comment_slash = &commentStyle{prefix: "//"}
+	comment_hash  = &commentStyle{prefix: "#"}
+	comment_dash  = &commentStyle{prefix: "--"}
+	comment_semi  = &commentStyle{prefix: ";"}
+	comment_xml   = &commentStyle{prefix: "<!--", suffix: "-->"}
+	comment_block = &commentStyle{prefix: "/*", suffix: "*/"}
+)
+
+// comment_styles maps file extensions to comment styles, files of other extensions use //
+var comment_styles = map[string]*commentStyle{
+	"java": comment_slash, "kt": comment_slash, "kts": comment_slash, "gradle": comment_slash, "groovy": comment_slash, "scala": comment_slash,
+	"js": comment_slash, "jsx": comment_slash, "ts": comment_slash, "tsx": comment_slash, "mjs": comment_slash,
+	"c": comment_slash, "h": comment_slash, "cc": comment_slash, "cpp": comment_slash, "hpp": comment_slash, "m": comment_slash, "mm": comment_slash,
+	"cs": comment_slash, "go": comment_slash, "swift": comment_slash, "dart": comment_slash, "rs": comment_slash, "php": comment_slash,
+	"py": comment_hash, "sh": comment_hash, "bash": comment_hash, "zsh": comment_hash, "rb": comment_hash, "pl": comment_hash,
+	"yaml": comment_hash, "yml": comment_hash, "toml": comment_hash, "properties": comment_hash, "conf": comment_hash, "cmake": comment_hash,
+	"lua": comment_dash, "sql": comment_dash,
+	"ini": comment_semi,
+	"xml": comment_xml, "md": comment_xml, "markdown": comment_xml, "html": comment_xml, "htm": comment_xml, "xhtml": comment_xml, "svg": comment_xml, "plist": comment_xml, "vue": comment_xml,
+	"css": comment_block,
+}
+
+// comment_file_names are the files known by name instead of extension
+var comment_file_names = map[string]*commentStyle{
+	"Makefile":       comment_hash,
+	"Dockerfile":     comment_hash,
+	"CMakeLists.txt": comment_hash,
+}
+
+// commentStyleOf detects the comment style from the file name
+func commentStyleOf(fileName string) *commentStyle {
+	if style, ok := comment_file_names[filepath.Base(fileName)]; ok {
+		return style
+	}
+	if style, ok := comment_styles[strings.TrimPrefix(filepath.Ext(fileName), ".")]; ok {
+		return style
+	}
+	return comment_slash
+}
+
+// parseCommentStyle parses the --comment flag, it is a file extension like py,
+// or the comment marks like "#" and "<!-- -->"
+func parseCommentStyle(text string) *commentStyle {
+	if style, ok := comment_styles[strings.TrimPrefix(text, ".")]; ok {
+		return style
+	}
+	marks := strings.Fields(text)
+	switch len(marks) {
+	case 1:
+		return &commentStyle{prefix: marks[0]}
+	case 2:
+		return &commentStyle{prefix: marks[0], suffix: marks[1]}
+	}
+	return nil
+}
+
+// comment makes the text a comment
+func (c *commentStyle) comment(text string) string {
+	if c.suffix == "" {
+		return c.prefix + text
+	}
+	return c.prefix + text + " " + c.suffix
+}
+
+// trimEnd removes the comment suffix at the end of line
+func (c *commentStyle) trimEnd(line string) string {
+	line = strings.TrimRight(line, " \t")
+	if c.suffix != "" && strings.HasSuffix(line, c.suffix) {
+		line = strings.TrimRight(strings.TrimSuffix(line, c.suffix), " \t")
+	}
+	return line
+}
+
+// findTag finds the tag right after the comment mark that starts the line, eg. // <else>,
+// so the tag text in code and strings is not taken as a tag
+func (c *commentStyle) findTag(line string, tagType int) (int, int) {
+	text := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(text, c.prefix) {
+		return -1, -1
+	}
+	text = strings.TrimLeft(text[len(c.prefix):], " \t")
+	start := len(line) - len(text)
+	if n := tagPrefixLen(text, tagType); n > 0 {
+		return start, start + n
+	}
+	return -1, -1
+}
+
+// uncomment removes the comment marks and one space after the prefix, the indent after the prefix is kept
+func (c *commentStyle) uncomment(line string) string {
+	text := c.trimEnd(strings.TrimLeft(line, " \t"))
+	if strings.HasPrefix(text, c.prefix) {
+		text = strings.TrimPrefix(text[len(c.prefix):], " ")
+	}
+	return text
+}
+
+// disabled_mark follows the comment prefix of the code disabled by in-place compile, eg. //~
+const disabled_mark = "~"
+
+// disable comments out the line with disabled_mark, blank lines and disabled lines are kept
+func (c *commentStyle) disable(line string) string {
+	text := strings.TrimLeft(line, " \t")
+	if text == "" || strings.HasPrefix(text, c.prefix+disabled_mark) {
+		return line
+	}
+	return lineIndent(line) + c.comment(disabled_mark+text)
+}
+
+// enable restores the line disabled by disable
+func (c *commentStyle) enable(line string) string {
+	text := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(text, c.prefix+disabled_mark) {
+		return line
+	}
+	text = text[len(c.prefix+disabled_mark):]
+	if c.suffix != "" {
+		text = strings.TrimSuffix(text, " "+c.suffix)
+	}
+	return lineIndent(line) + text
+}
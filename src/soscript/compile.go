@@ -0,0 +1,330 @@
+package soscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// compileSource compiles the lexed source, the lexer is rewound so that the source can be compiled with other configs.
+// It returns the output, the number of soscript blocks and the number of changed blocks, the output is empty when the parser has any error
+func compileSource(parser *parser, sourceLexer *lexer, inPlace bool) (string, int, int) {
+	sourceLexer.reset()
+	parser.parseSourceCode(sourceLexer)
+	blocks := len(parser.soscriptList) + len(parser.ifBlockList)
+	if parser.hasError() {
+		return "", blocks, 0
+	}
+	generator := newSourceGen(inPlace, parser)
+	generator.gen()
+	return generator.output, blocks, generator.changedBlocks
+}
+
+// compileFile compiles one source file with the loaded parser,
+// returns whether the output differs from the source, the number of soscript blocks and the number of changed blocks.
+// Nothing is generated when the parser has any error. The comment style is detected from the file name when comment is nil.
+// A new output file takes the mode of the source file.
+func compileFile(parser *parser, sourceFilePath string, outputFilePath string, inPlace bool, comment *commentStyle) (bool, int, int, error) {
+	info, err := os.Stat(sourceFilePath)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	source, err := ioutil.ReadFile(sourceFilePath)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	sourceLexer := newSourceLexer(sourceFilePath, comment, bytes.NewReader(source))
+	output, blocks, changedBlocks := compileSource(parser, sourceLexer, inPlace)
+	if parser.hasError() {
+		return false, blocks, 0, nil
+	}
+	if err := ioutil.WriteFile(outputFilePath, []byte(output), info.Mode().Perm()); err != nil {
+		return false, blocks, 0, err
+	}
+	return output != string(source), blocks, changedBlocks, nil
+}
+
+// compileStreamFile compiles the source file with streaming lexer, so that only the lines of the current block are kept in memory.
+// The output is written to a temporary file beside the output file and renamed to it when there is no error,
+// so that the source file can be compiled in place. The output file takes the mode of the source file.
+func compileStreamFile(parser *parser, sourceFilePath string, outputFilePath string, inPlace bool, comment *commentStyle) (bool, int, int, error) {
+	source, err := os.Open(sourceFilePath)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if parser.hasError() {
+		// the source is still compiled for its errors, but nothing is written
+		blocks, err := newSourceGen(inPlace, parser).genStream(newStreamLexer(sourceFilePath, comment, source, ioutil.Discard))
+		return false, blocks, 0, err
+	}
+	output, err := ioutil.TempFile(filepath.Dir(outputFilePath), "."+filepath.Base(outputFilePath)+".*")
+	if err != nil {
+		return false, 0, 0, err
+	}
+	defer os.Remove(output.Name())
+	writer := bufio.NewWriter(output)
+	generator := newSourceGen(inPlace, parser)
+	blocks, err := generator.genStream(newStreamLexer(sourceFilePath, comment, source, writer))
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, blocks, 0, err
+	}
+	changed := generator.changedBlocks > 0
+	// the unchanged source is not rewritten in place
+	if parser.hasError() || (!changed && outputFilePath == sourceFilePath) {
+		return false, blocks, 0, nil
+	}
+	if err := os.Chmod(output.Name(), info.Mode().Perm()); err != nil {
+		return false, blocks, 0, err
+	}
+	if err := os.Rename(output.Name(), outputFilePath); err != nil {
+		return false, blocks, 0, err
+	}
+	return changed, blocks, generator.changedBlocks, nil
+}
+
+// Summary counts the files and blocks compiled from a directory
+type Summary struct {
+	Files         int
+	CopiedFiles   int
+	CompiledFiles int
+	ChangedFiles  int
+	Blocks        int
+	ChangedBlocks int
+	ChangedPaths  []string // slash separated paths relative to the source directory
+}
+
+func (s *Summary) String() string {
+	return fmt.Sprintf("%d files: %d compiled, %d copied, %d changed; %d soscript blocks, %d changed",
+		s.Files, s.CompiledFiles, s.CopiedFiles, s.ChangedFiles, s.Blocks, s.ChangedBlocks)
+}
+
+// sourceFile is a file or a directory under the source dir,
+// the file with soscript is lexed once and kept in memory, so that it can be compiled more than once.
+// The file of streaming compile is not loaded, it is read with streaming lexer when it is compiled
+type sourceFile struct {
+	path    string
+	relPath string
+	isDir   bool
+	mode    os.FileMode
+	source  string
+	lexer   *lexer // nil for the file copied as it is
+	stream  bool
+	comment *commentStyle // comment style of streaming compile
+}
+
+// loadSourceDir walks the source dir and lexes the files with soscript, the output dir under the source dir is skipped,
+// outputDir is empty when there is no output. The files are not loaded for streaming compile
+func loadSourceDir(sourceDir string, outputDir string, stream bool, comment *commentStyle, includes []string, excludes []string) ([]*sourceFile, error) {
+	var files []*sourceFile
+	absOutputDir, _ := filepath.Abs(outputDir)
+	err := filepath.Walk(sourceDir, func(sourcePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, sourcePath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if relPath != "." && matchPatterns(excludes, relPath) {
+				return filepath.SkipDir
+			}
+			// do not walk into the output dir when it is under the source dir
+			if absPath, _ := filepath.Abs(sourcePath); relPath != "." && outputDir != "" && absPath == absOutputDir {
+				return filepath.SkipDir
+			}
+			files = append(files, &sourceFile{path: sourcePath, relPath: relPath, isDir: true, mode: info.Mode().Perm()})
+			return nil
+		}
+		if matchPatterns(excludes, relPath) || (len(includes) > 0 && !matchPatterns(includes, relPath)) {
+			return nil
+		}
+		if stream {
+			files = append(files, &sourceFile{path: sourcePath, relPath: relPath, mode: info.Mode().Perm(), stream: true, comment: comment})
+			return nil
+		}
+		file, err := loadSourceFile(sourcePath, relPath, info.Mode().Perm(), comment)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+func loadSourceFile(sourcePath string, relPath string, mode os.FileMode, comment *commentStyle) (*sourceFile, error) {
+	source, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	return newSourceFile(sourcePath, relPath, mode, source, comment), nil
+}
+
+func newSourceFile(sourcePath string, relPath string, mode os.FileMode, source []byte, comment *commentStyle) *sourceFile {
+	file := &sourceFile{path: sourcePath, relPath: relPath, mode: mode, source: string(source)}
+	if comment == nil {
+		comment = commentStyleOf(sourcePath)
+	}
+	if hasSoscript(source, comment) {
+		file.lexer = newSourceLexer(sourcePath, comment, bytes.NewReader(source))
+	}
+	return file
+}
+
+// hasSoscript tells whether a <soscript> block or an if block starts in the source, the tag should follow the comment mark
+// at the start of a line the same as the lexer takes it, so the file that only mentions the tags is copied as it is
+func hasSoscript(source []byte, comment *commentStyle) bool {
+	if !bytes.Contains(source, []byte("<soscript>")) && !bytes.Contains(source, []byte("<if")) {
+		return false
+	}
+	for _, line := range strings.Split(string(source), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if start, _ := comment.findTag(line, tag_soscript_start); start >= 0 {
+			return true
+		}
+		if start, _ := comment.findTag(line, tag_if_start); start >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSourceFiles compiles the loaded files into the output dir with the current config of parser.
+// Nothing is written once the parser has any error, the files after it are still compiled for their errors
+func compileSourceFiles(parser *parser, files []*sourceFile, outputDir string, inPlace bool) (*Summary, error) {
+	summary := &Summary{}
+	for _, file := range files {
+		outputPath := filepath.Join(outputDir, file.relPath)
+		if file.isDir {
+			if parser.hasError() {
+				continue
+			}
+			if err := os.MkdirAll(outputPath, file.mode|0700); err != nil {
+				return summary, err
+			}
+			continue
+		}
+		summary.Files++
+		if file.stream {
+			changed, blocks, changedBlocks, err := compileStreamFile(parser, file.path, outputPath, inPlace, file.comment)
+			if err != nil {
+				return summary, err
+			}
+			if parser.hasError() {
+				continue
+			}
+			if blocks == 0 {
+				if outputPath != file.path {
+					summary.CopiedFiles++
+				}
+				continue
+			}
+			summary.add(file.relPath, changed, blocks, changedBlocks)
+			continue
+		}
+		if file.lexer == nil {
+			if outputPath != file.path && !parser.hasError() {
+				summary.CopiedFiles++
+				if err := ioutil.WriteFile(outputPath, []byte(file.source), file.mode); err != nil {
+					return summary, err
+				}
+			}
+			continue
+		}
+		output, blocks, changedBlocks := compileSource(parser, file.lexer, inPlace)
+		if parser.hasError() {
+			continue
+		}
+		if err := ioutil.WriteFile(outputPath, []byte(output), file.mode); err != nil {
+			return summary, err
+		}
+		summary.add(file.relPath, output != file.source, blocks, changedBlocks)
+	}
+	return summary, nil
+}
+
+// add counts a compiled file
+func (s *Summary) add(relPath string, changed bool, blocks int, changedBlocks int) {
+	s.CompiledFiles++
+	s.Blocks += blocks
+	s.ChangedBlocks += changedBlocks
+	if changed {
+		s.ChangedFiles++
+		s.ChangedPaths = append(s.ChangedPaths, filepath.ToSlash(relPath))
+	}
+}
+
+// compileFS compiles the files of fsys, the compiled files and the files without soscript are given to write by their path in fsys.
+// The files are read one by one, so that only one file is kept in memory. Nothing is written once the parser has any error
+func compileFS(parser *parser, fsys fs.FS, comment *commentStyle, inPlace bool, includes []string, excludes []string, write func(name string, data []byte) error) (*Summary, error) {
+	summary := &Summary{}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name != "." && matchPatterns(excludes, name) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if matchPatterns(excludes, name) || (len(includes) > 0 && !matchPatterns(includes, name)) {
+			return nil
+		}
+		source, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		summary.Files++
+		file := newSourceFile(name, name, 0, source, comment)
+		if file.lexer == nil {
+			// the files after an error are still compiled for their errors, but nothing is written
+			if parser.hasError() {
+				return nil
+			}
+			summary.CopiedFiles++
+			return write(name, source)
+		}
+		output, blocks, changedBlocks := compileSource(parser, file.lexer, inPlace)
+		if parser.hasError() {
+			return nil
+		}
+		summary.add(name, output != file.source, blocks, changedBlocks)
+		return write(name, []byte(output))
+	})
+	return summary, err
+}
+
+// matchPatterns matches the slash separated relative path with glob patterns,
+// pattern without "/" is matched with the base name only
+func matchPatterns(patterns []string, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, pattern := range patterns {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relPath)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package soscript
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// every line keeps its own line break, with and without streaming
+func TestMixedLineBreaks(t *testing.T) {
+	source := "a\r\nb\n" + strings.ReplaceAll(testSource, "\n", "\r\n") + "c\nd\r\ne"
+	tests := []struct {
+		inPlace bool
+		output  string
+	}{
+		{false, "a\r\nb\nint a = 1;\r\nc\nd\r\ne"},
+		{true, "a\r\nb\n" + strings.ReplaceAll(`// <soscript>
+// <default>
+int a = 1;
+// <origin>int a = 0;</origin>
+// </default>
+// <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+// </soscript>
+`, "\n", "\r\n") + "c\nd\r\ne"},
+	}
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	for _, test := range tests {
+		for _, stream := range []bool{false, true} {
+			var out bytes.Buffer
+			opts := &Options{Name: "src.java", InPlace: test.inPlace, Stream: stream}
+			if err := Compile(strings.NewReader(source), &out, cfg, opts); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.output {
+				t.Errorf("in-place %v, stream %v: got %q, want %q", test.inPlace, stream, out.String(), test.output)
+			}
+		}
+	}
+
+	// the source without soscript is written as it is
+	source = "a\r\nb\nc\r\n"
+	cfg = loadTestConfig(t, testDefs, `platform = "ios"`)
+	for _, stream := range []bool{false, true} {
+		var out bytes.Buffer
+		if err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "src.java", Stream: stream}); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != source {
+			t.Errorf("stream %v: got %q, want %q", stream, out.String(), source)
+		}
+	}
+}
+
+// the files that only mention the tags in code, strings or docs are copied as they are
+func TestCompileFSCopiesTagText(t *testing.T) {
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"README.md":   {Data: readme},
+		"Tags.java":   {Data: []byte("String start = \"<soscript>\";\r\nString cond = \"<if cond=\";\n")},
+		"script.py":   {Data: []byte("// <soscript>\nprint('<if cond=\"a\">')\n")},
+		"Source.java": {Data: []byte(testSource)},
+	}
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	written, summary, err := compileTestFS(t, fsys, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"README.md":   string(readme),
+		"Tags.java":   string(fsys["Tags.java"].Data),
+		"script.py":   string(fsys["script.py"].Data),
+		"Source.java": "int a = 1;\n",
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("got written %q, want %q", written, want)
+	}
+	if summary.CopiedFiles != 3 || summary.CompiledFiles != 1 {
+		t.Errorf("got summary %v", summary)
+	}
+}
+
+// listFiles returns the files under dir with their contents, the dirs are listed with a slash
+func listFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	ret := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		relPath, _ := filepath.Rel(dir, path)
+		if entry.IsDir() {
+			ret[filepath.ToSlash(relPath)+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		ret[filepath.ToSlash(relPath)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+// nothing is written after the file with any error, the files after it are still compiled for their errors
+func TestCompileDirErrors(t *testing.T) {
+	bad := strings.Replace(testSource, `"android"`, `"andriod"`, 1)
+	sourceDir := t.TempDir()
+	for name, data := range map[string]string{
+		"0.txt":     "before",
+		"a.java":    bad,
+		"b.txt":     "after",
+		"c.java":    testSource,
+		"e.java":    strings.Replace(bad, "andriod", "iso", 1),
+		"sub/d.txt": "after",
+	} {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	for _, stream := range []bool{false, true} {
+		outputDir := filepath.Join(t.TempDir(), "out")
+		_, err := CompileDir(sourceDir, outputDir, cfg, &Options{Stream: stream})
+		checkDiagnostics(t, err, []string{
+			filepath.Join(sourceDir, "a.java") + `:5: value "andriod" is not declared for platform`,
+			filepath.Join(sourceDir, "e.java") + `:5: value "iso" is not declared for platform`,
+		})
+		if got, want := listFiles(t, outputDir), map[string]string{"0.txt": "before"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("stream %v: got output %q, want %q", stream, got, want)
+		}
+	}
+}
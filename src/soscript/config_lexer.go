@@ -0,0 +1,256 @@
+package soscript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config files of JSON, YAML, TOML and .env are lexed into the same tokens as .ss assigns: name = value,
+// so that they are checked by parse_assign. Quoted values are STRING tokens, other values are token_bare
+// and converted to the declared type of the variable. Only flat key value configs are supported.
+
+// bare_number_rule is the unquoted value that can be assigned to int and float variables
+var bare_number_rule = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// configFileType is the lexer file type of config file by extension, .ss is used for unknown extensions
+func configFileType(fileName string) string {
+	base := filepath.Base(fileName)
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".env":
+		return "env"
+	}
+	// .env.local, .env.production
+	if strings.HasPrefix(base, ".env") {
+		return "env"
+	}
+	return "ss"
+}
+
+func newConfigLexer(fileName string, reader io.Reader) *lexer {
+	return newLexer(configFileType(fileName), fileName, reader)
+}
+
+// env_var_prefix is the prefix of environment variables that assign variables, eg. SSC_VAR_platform=ios
+const env_var_prefix = "SSC_VAR_"
+
+// newEnvConfigLexer lexes the SSC_VAR_<name> environment variables as a .env config,
+// the name is matched with the declared variables ignoring case, so SSC_VAR_PLATFORM assigns platform.
+// The dot of imported variables is written as __, eg. SSC_VAR_COMMON__PLATFORM assigns common.platform
+func newEnvConfigLexer(environ []string, varNames []string) *lexer {
+	var lines []string
+	for _, v := range environ {
+		if strings.HasPrefix(v, env_var_prefix) {
+			lines = append(lines, v)
+		}
+	}
+	sort.Strings(lines)
+	lexer := newLexer("env", "environment", strings.NewReader(strings.Join(lines, "\n")))
+	for _, token := range lexer.tokens {
+		if token.tokenType != token_symbol {
+			continue
+		}
+		token.text = strings.TrimPrefix(token.text, env_var_prefix)
+		token.column += len(env_var_prefix)
+		matched := token.text
+		for _, name := range varNames {
+			if name == token.text {
+				matched = name
+				break
+			}
+			if strings.EqualFold(strings.ReplaceAll(name, ".", "__"), token.text) {
+				matched = name
+			}
+		}
+		token.text = matched
+	}
+	return lexer
+}
+
+// start_json lexes the whole JSON object, the positions of tokens are found by the input offset of decoder
+func (lexer *lexer) start_json() {
+	text := strings.Join(lexer.lines, "\n")
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	// nextPos is the position of the next token, the separators consumed by decoder are skipped
+	nextPos := func() (int, int) {
+		offset := int(decoder.InputOffset())
+		for offset < len(text) && strings.IndexByte(" \t\r\n,:", text[offset]) >= 0 {
+			offset++
+		}
+		lineno := strings.Count(text[:offset], "\n") + 1
+		return lineno, offset - strings.LastIndex(text[:offset], "\n")
+	}
+	lineno, column := nextPos()
+	token, err := decoder.Token()
+	if err != nil || token != json.Delim('{') {
+		lexer.error(lineno, column, "config should be a JSON object")
+		return
+	}
+	for decoder.More() {
+		lineno, column := nextPos()
+		key, err := decoder.Token()
+		if err != nil {
+			lexer.error(lineno, column, fmt.Sprintf("invalid JSON: %s", err.Error()))
+			return
+		}
+		valLineno, valColumn := nextPos()
+		val, err := decoder.Token()
+		if err != nil {
+			lexer.error(valLineno, valColumn, fmt.Sprintf("invalid JSON: %s", err.Error()))
+			return
+		}
+		tokenType, text := token_bare, ""
+		switch v := val.(type) {
+		case string:
+			tokenType, text = token_string, strconv.Quote(v)
+		case json.Number:
+			text = v.String()
+		case bool:
+			text = strconv.FormatBool(v)
+		case nil:
+			lexer.error(valLineno, valColumn, fmt.Sprintf("value of %s is null", key))
+			continue
+		default:
+			lexer.error(valLineno, valColumn, fmt.Sprintf("value of %s is nested, config should be a flat JSON object", key))
+			return
+		}
+		lexer.addToken(lineno, column+1, token_symbol, key.(string))
+		lexer.addToken(valLineno, valColumn, token_assign, ":")
+		lexer.addToken(valLineno, valColumn, tokenType, text)
+	}
+}
+
+// start_yaml lexes a line of flat YAML mapping, eg. platform: ios
+func (lexer *lexer) start_yaml(lineno int, line string) {
+	text := strings.TrimSpace(line)
+	if text == "" || strings.HasPrefix(text, "#") || text == "---" || text == "..." {
+		return
+	}
+	if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(text, "-") {
+		lexer.error(lineno, 1, "nested value is not supported, config should be a flat YAML mapping")
+		return
+	}
+	idx := strings.Index(line, ":")
+	if idx < 0 || (idx+1 < len(line) && line[idx+1] != ' ' && line[idx+1] != '\t') {
+		lexer.error(lineno, 1, "expected name: value")
+		return
+	}
+	lexer.lex_config_assign(lineno, line, idx)
+}
+
+// start_toml lexes a line of TOML without tables, eg. platform = "ios"
+func (lexer *lexer) start_toml(lineno int, line string) {
+	text := strings.TrimSpace(line)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return
+	}
+	if strings.HasPrefix(text, "[") {
+		lexer.error(lineno, strings.Index(line, "[")+1, "table is not supported, config should be flat TOML")
+		return
+	}
+	idx := strings.Index(line, "=")
+	if idx < 0 {
+		lexer.error(lineno, 1, "expected name = value")
+		return
+	}
+	lexer.lex_config_assign(lineno, line, idx)
+}
+
+// start_env lexes a line of .env, eg. platform=ios or export platform="ios"
+func (lexer *lexer) start_env(lineno int, line string) {
+	text := strings.TrimSpace(line)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return
+	}
+	idx := strings.Index(line, "=")
+	if idx < 0 {
+		lexer.error(lineno, 1, "expected name=value")
+		return
+	}
+	lexer.lex_config_assign(lineno, line, idx)
+}
+
+// lex_config_assign lexes the name before idx and the value after idx, idx is the offset of = or :
+func (lexer *lexer) lex_config_assign(lineno int, line string, idx int) {
+	name := line[:idx]
+	if lexer.fileType == "env" {
+		name = strings.TrimPrefix(strings.TrimLeft(name, " \t"), "export ")
+	}
+	name = strings.TrimSpace(name)
+	val := strings.TrimLeft(line[idx+1:], " \t")
+	offset := len(line) - len(val)
+	if val == "" || strings.HasPrefix(val, "#") {
+		lexer.error(lineno, offset+1, fmt.Sprintf("%s has no value", name))
+		return
+	}
+	tokenType, text, ok := lexer.lex_config_value(lineno, line, offset)
+	if !ok {
+		return
+	}
+	lexer.addToken(lineno, strings.Index(line, name)+1, token_symbol, name)
+	lexer.addToken(lineno, idx+1, token_assign, line[idx:idx+1])
+	lexer.addToken(lineno, offset+1, tokenType, text)
+}
+
+// lex_config_value lexes the value starts at offset, the comment after the value is skipped
+func (lexer *lexer) lex_config_value(lineno int, line string, offset int) (int, string, bool) {
+	val := line[offset:]
+	rest := ""
+	tokenType, text := token_string, ""
+	switch val[0] {
+	case '"':
+		end := 1
+		for end < len(val) && val[end] != '"' {
+			if val[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(val) {
+			lexer.error(lineno, offset+1, "missing \" of string")
+			return 0, "", false
+		}
+		s, err := strconv.Unquote(val[:end+1])
+		if err != nil {
+			lexer.error(lineno, offset+1, fmt.Sprintf("invalid string %s", val[:end+1]))
+			return 0, "", false
+		}
+		text = strconv.Quote(s)
+		rest = val[end+1:]
+	case '\'':
+		end := strings.Index(val[1:], "'")
+		if end < 0 {
+			lexer.error(lineno, offset+1, "missing ' of string")
+			return 0, "", false
+		}
+		text = strconv.Quote(val[1 : end+1])
+		rest = val[end+2:]
+	default:
+		// the comment after the value starts with space and #
+		if idx := strings.Index(val, " #"); idx >= 0 {
+			val = val[:idx]
+		}
+		if idx := strings.Index(val, "\t#"); idx >= 0 {
+			val = val[:idx]
+		}
+		tokenType, text = token_bare, strings.TrimSpace(val)
+	}
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		lexer.error(lineno, len(line)-len(rest)+1, fmt.Sprintf("unexpected %q after value", rest))
+		return 0, "", false
+	}
+	return tokenType, text, true
+}
@@ -0,0 +1,196 @@
+package soscript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const configDefs = testDefs + `
+mode: {"debug", "release"}
+	optional
+
+enableLog: bool
+	default false
+
+ratio: float
+	default 0.5
+
+addr: string
+	optional
+`
+
+// configValues is the current value of every variable of cfg
+func configValues(cfg *Config) map[string]string {
+	ret := map[string]string{}
+	for _, name := range cfg.parser.varNameList {
+		ret[name] = cfg.parser.varDeclareSet[name].currVal
+	}
+	return ret
+}
+
+// the configs of every format assign the same values, unquoted values are converted to the declared types
+func TestConfigFormats(t *testing.T) {
+	want := map[string]string{
+		"platform":  `"ios"`,
+		"level":     "4",
+		"mode":      `"release"`,
+		"enableLog": "true",
+		"ratio":     "1.5",
+		"addr":      `"http://a.com/#1"`,
+	}
+	configs := map[string]string{
+		"conf.ss": `platform = "ios"
+level = 4
+mode = "release"
+enableLog = true
+ratio = 1.5
+addr = "http://a.com/#1"`,
+		"conf.json": `{
+	"platform": "ios",
+	"level": 4,
+	"mode": "release",
+	"enableLog": true,
+	"ratio": 1.5,
+	"addr": "http://a.com/#1"
+}`,
+		"conf.yaml": `---
+# build config
+platform: ios
+level: 4
+mode: 'release'
+enableLog: true
+ratio: 1.5 # comment
+addr: "http://a.com/#1"
+`,
+		"conf.toml": `# build config
+platform = "ios"
+level = 4
+mode = 'release'
+enableLog = true
+ratio = 1.5
+addr = "http://a.com/#1"
+`,
+		".env.local": `# build config
+platform=ios
+export level=4
+mode="release"
+enableLog=true
+ratio=1.5
+addr="http://a.com/#1"
+`,
+	}
+	for name, config := range configs {
+		cfg := loadTestConfig(t, configDefs, "")
+		if err := cfg.Load(name, strings.NewReader(config)); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Err(); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := configValues(cfg); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfigFormatErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		diagnostics []string
+	}{
+		{"conf.json", `["ios"]`, []string{"conf.json:1: config should be a JSON object"}},
+		{"conf.json", `{"platform": {"name": "ios"}}`, []string{"conf.json:1: value of platform is nested, config should be a flat JSON object"}},
+		{"conf.json", "{\n\"level\": \"high\"\n}", []string{"conf.json:2: "}},
+		{"conf.yaml", "app:\n  platform: ios", []string{"conf.yaml:1: app has no value", "conf.yaml:2: nested value is not supported, config should be a flat YAML mapping"}},
+		{"conf.yaml", "level: 9", []string{"conf.yaml:1: "}},
+		{"conf.toml", "[app]\nplatform = \"ios\"", []string{"conf.toml:1: table is not supported, config should be flat TOML"}},
+		{".env", "platform=andriod", []string{`.env:1: value "andriod" is not declared for platform`}},
+		{".env", "platform", []string{".env:1: expected name=value"}},
+	}
+	for _, test := range tests {
+		cfg := loadTestConfig(t, configDefs, "")
+		if err := cfg.Load(test.name, strings.NewReader(test.config)); err != nil {
+			t.Fatal(err)
+		}
+		checkDiagnostics(t, cfg.Err(), test.diagnostics)
+	}
+}
+
+// extends assigns the base config first, the path is relative to the config file
+func TestConfigExtends(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"base/base_conf.ss": "platform = \"android\"\nlevel = 2\nmode = \"debug\"",
+		"conf.ss":           "extends \"base/base_conf.ss\"\nlevel = 5",
+		"cycle_a.ss":        "extends \"cycle_b.ss\"\nlevel = 1",
+		"cycle_b.ss":        "extends \"cycle_a.ss\"\nlevel = 2",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := loadTestConfig(t, configDefs, "")
+	if err := cfg.LoadFile(filepath.Join(dir, "conf.ss")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Err(); err != nil {
+		t.Fatal(err)
+	}
+	got := configValues(cfg)
+	if got["platform"] != `"android"` || got["level"] != "5" || got["mode"] != `"debug"` {
+		t.Errorf("got %q", got)
+	}
+
+	cfg = loadTestConfig(t, configDefs, "")
+	if err := cfg.LoadFile(filepath.Join(dir, "cycle_a.ss")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Err() == nil {
+		t.Error("got no error of the extends cycle")
+	}
+}
+
+// SSC_VAR_ environment variables are assigned over the config files, and -D over them
+func TestConfigPrecedence(t *testing.T) {
+	cfg := loadTestConfig(t, configDefs, "platform = \"android\"\nlevel = 2\nmode = \"debug\"")
+	cfg.LoadEnv([]string{"SSC_VAR_LEVEL=4", "SSC_VAR_mode=release", "HOME=/root", "SSC_VAR_ENABLELOG=true"})
+	cfg.Set("level=5", "addr=localhost")
+	if err := cfg.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"platform":  `"android"`,
+		"level":     "5",
+		"mode":      `"release"`,
+		"enableLog": "true",
+		"ratio":     "0.5",
+		"addr":      `"localhost"`,
+	}
+	if got := configValues(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	sources := map[string]string{}
+	for _, name := range []string{"platform", "level", "mode"} {
+		sources[name] = cfg.parser.varDeclareSet[name].source()
+	}
+	wantSources := map[string]string{
+		"platform": `conf.ss:1: platform = "android"`,
+		"level":    "-D:1: level=5",
+		"mode":     "environment:3: SSC_VAR_mode=release",
+	}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("got sources %q, want %q", sources, wantSources)
+	}
+
+	cfg.Set("level=9")
+	checkDiagnostics(t, cfg.Err(), []string{"-D:1: "})
+}
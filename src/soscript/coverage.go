@@ -0,0 +1,172 @@
+package soscript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	COVERAGE_NEVER       = "never"
+	COVERAGE_ALWAYS      = "always"
+	COVERAGE_PARTIAL     = "partial"
+	COVERAGE_UNREACHABLE = "unreachable"
+)
+
+// LineCoverage counts the combinations that select a <line>, a branch of if block or the <default> of soscript block.
+// Fields are exported for the JSON report.
+type LineCoverage struct {
+	File      string `json:"file"`
+	Lineno    int    `json:"line"`
+	Branch    string `json:"branch"`
+	Condition string `json:"condition,omitempty"`
+	Hits      int    `json:"hits"`
+	Status    string `json:"status"`
+}
+
+// CoverageReport counts the hits of each branch with the combinations that have no error
+type CoverageReport struct {
+	Combinations int             `json:"combinations"`
+	Failed       int             `json:"failed"`
+	Lines        []*LineCoverage `json:"lines"`
+}
+
+// coverageBranches lists the branches of the parsed source with whether they are selected by the current config,
+// the order is the same for every config, so the branches of different configs are matched by index
+func coverageBranches(parser *parser, fileName string) ([]*LineCoverage, []bool) {
+	var ret []*LineCoverage
+	var selected []bool
+	for _, soscript := range parser.soscriptList {
+		ret = append(ret, &LineCoverage{File: fileName, Lineno: soscript.defaultStartLineno, Branch: "default"})
+		selected = append(selected, !soscript.matched)
+		for _, line := range soscript.lineList {
+			if line.lineType == line_type_assign {
+				continue
+			}
+			branch := &LineCoverage{File: fileName, Lineno: line.token.lineno, Branch: line.token.text}
+			if line.expr != nil {
+				branch.Condition = line.expr.String()
+			}
+			ret = append(ret, branch)
+			selected = append(selected, line.selected)
+		}
+	}
+	for _, block := range parser.ifBlockList {
+		for _, v := range block.branchList {
+			branch := &LineCoverage{File: fileName, Lineno: v.token.lineno, Branch: strings.Trim(v.token.text, "<>")}
+			if v.expr != nil {
+				branch.Condition = v.expr.String()
+			}
+			ret = append(ret, branch)
+			selected = append(selected, v.selected)
+		}
+	}
+	return ret, selected
+}
+
+// analyzeCoverage evaluates the source files with every combination of variable values, the hits of a combination
+// are counted only when it has no error. The failed combinations are reported to errLog grouped by their diagnostics
+func analyzeCoverage(parser *parser, files []*sourceFile, filterText string, errLog io.Writer) (*CoverageReport, error) {
+	filter, err := parseFilter(parser, filterText)
+	if err != nil {
+		return nil, err
+	}
+	vars := matrixVars(parser)
+	report := &CoverageReport{}
+	fileBranches := make([][]*LineCoverage, len(files))
+	reporter := newCombinationReporter(parser, errLog)
+	reporter.groupFailures = true
+	forEachCombination(vars, func() {
+		var fileHits [][]bool
+		selected, err := evalFilter(parser, filter)
+		if err != nil {
+			parser.addError(err.token, err.message)
+			report.Combinations++
+		} else if selected {
+			report.Combinations++
+			fileHits = make([][]bool, len(files))
+			for i, file := range files {
+				if file.lexer == nil {
+					continue
+				}
+				file.lexer.reset()
+				parser.parseSourceCode(file.lexer)
+				branches, hits := coverageBranches(parser, file.relPath)
+				if fileBranches[i] == nil {
+					fileBranches[i] = branches
+				}
+				fileHits[i] = hits
+			}
+		}
+		if reporter.flush(vars) {
+			report.Failed++
+			return
+		}
+		for i, hits := range fileHits {
+			for j, v := range hits {
+				if v && j < len(fileBranches[i]) {
+					fileBranches[i][j].Hits++
+				}
+			}
+		}
+	})
+	reporter.writeFailures()
+	passed := report.passed()
+	report.Lines = make([]*LineCoverage, 0)
+	for _, branches := range fileBranches {
+		for _, v := range branches {
+			switch {
+			case v.Hits == 0 && v.Branch == "default":
+				v.Status = COVERAGE_UNREACHABLE
+			case v.Hits == 0:
+				v.Status = COVERAGE_NEVER
+			case v.Hits == passed:
+				v.Status = COVERAGE_ALWAYS
+			default:
+				v.Status = COVERAGE_PARTIAL
+			}
+			report.Lines = append(report.Lines, v)
+		}
+	}
+	if report.Failed > 0 {
+		return report, fmt.Errorf("coverage failed with %d of %d combination(s)", report.Failed, report.Combinations)
+	}
+	return report, nil
+}
+
+// passed is the number of combinations that have no error
+func (r *CoverageReport) passed() int {
+	return r.Combinations - r.Failed
+}
+
+func (r *CoverageReport) count(status string) int {
+	count := 0
+	for _, v := range r.Lines {
+		if v.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteTable prints the branches in a table with the counts of never, always and unreachable branches
+func (r *CoverageReport) WriteTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tLINE\tBRANCH\tHITS\tSTATUS\tCONDITION")
+	for _, v := range r.Lines {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d/%d\t%s\t%s\n", v.File, v.Lineno, v.Branch, v.Hits, r.passed(), v.Status, v.Condition)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d combinations, %d failed; %d branches: %d never, %d always, %d unreachable default\n",
+		r.Combinations, r.Failed, len(r.Lines), r.count(COVERAGE_NEVER), r.count(COVERAGE_ALWAYS), r.count(COVERAGE_UNREACHABLE))
+}
+
+// WriteJSON prints the report in indented JSON
+func (r *CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
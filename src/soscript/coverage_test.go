@@ -0,0 +1,119 @@
+package soscript
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const coverageDefs = `platform: {"android", "ios", "h5"}
+
+addr: string
+	optional
+`
+
+// coverageSource fails with the combinations of ios, addr is not assigned
+const coverageSource = `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+// <line> elif(platform != "h5") print(<code> a = <var>addr</var>; </code>) </line>
+// <line> elif(platform == "h5") print(<code> int a = 3; </code>) </line>
+// </soscript>
+`
+
+// testCoverage analyzes the source with every combination of the variables not assigned by config
+func testCoverage(t *testing.T, source string, config string) (*CoverageReport, string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a.java")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := LoadSources(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errLog bytes.Buffer
+	report, err := Coverage(loadTestConfig(t, coverageDefs, config), sources, &Options{ErrLog: &errLog})
+	return report, strings.ReplaceAll(errLog.String(), path, "a.java"), err
+}
+
+// coverageLines is the branch, hits and status of every line of report
+func coverageLines(report *CoverageReport) string {
+	var lines []string
+	for _, v := range report.Lines {
+		lines = append(lines, fmt.Sprintf("%d %s %d %s", v.Lineno, v.Branch, v.Hits, v.Status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCoverage(t *testing.T) {
+	report, errLog, err := testCoverage(t, coverageSource, `addr = "localhost"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `2 default 0 unreachable
+5 if 1 partial
+6 elif 1 partial
+7 elif 1 partial`
+	if got := coverageLines(report); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if report.Combinations != 3 || report.Failed != 0 || errLog != "" {
+		t.Errorf("got %d combinations, %d failed, error log %q", report.Combinations, report.Failed, errLog)
+	}
+}
+
+// the hits of a failed combination are not counted, the failed combinations are reported once for each diagnostic
+func TestCoverageFailed(t *testing.T) {
+	source := strings.Replace(coverageSource, "// </soscript>", `// <line> else print(<code> <var>host</var> </code>) </line>
+// </soscript>`, 1)
+	report, errLog, err := testCoverage(t, source, "")
+	if err == nil || err.Error() != "coverage failed with 3 of 3 combination(s)" {
+		t.Errorf("got error %v", err)
+	}
+	// every combination fails with the undefined host, ios with addr too
+	if report.Combinations != 3 || report.Failed != 3 {
+		t.Errorf("got %d combinations, %d failed", report.Combinations, report.Failed)
+	}
+	for _, v := range report.Lines {
+		if v.Hits != 0 {
+			t.Errorf("line %d: got %d hits of failed combinations", v.Lineno, v.Hits)
+		}
+	}
+	want := []string{
+		`failed: platform="android"; platform="h5"`,
+		`failed: platform="ios"`,
+	}
+	var got []string
+	for _, line := range strings.Split(errLog, "\n") {
+		if strings.HasPrefix(line, "failed: ") {
+			got = append(got, line)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got failed lines %q, want %q", got, want)
+	}
+	if n := strings.Count(errLog, "variable host is not defined"); n != 2 {
+		t.Errorf("got the host error %d times in\n%s", n, errLog)
+	}
+	if n := strings.Count(errLog, "variable addr is not assigned in config"); n != 1 {
+		t.Errorf("got the addr error %d times in\n%s", n, errLog)
+	}
+
+	report, _, err = testCoverage(t, coverageSource, "")
+	if err == nil || report.Failed != 1 {
+		t.Fatalf("got error %v", err)
+	}
+	want2 := `2 default 0 unreachable
+5 if 1 partial
+6 elif 0 never
+7 elif 1 partial`
+	if got := coverageLines(report); got != want2 {
+		t.Errorf("got\n%s\nwant\n%s", got, want2)
+	}
+}
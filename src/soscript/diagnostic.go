@@ -0,0 +1,130 @@
+package soscript
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const SEVERITY_ERROR = "error"
+
+// Diagnostic is an error or warning found in def, config or source file
+type Diagnostic struct {
+	File     string
+	Lineno   int
+	Column   int // 1-based, counted in bytes
+	Severity string
+	Message  string
+	Snippet  string // the source line, empty when the diagnostic is not on a line
+}
+
+func newDiagnostic(severity string, fileName string, lineno int, column int, message string, snippet string) *Diagnostic {
+	return &Diagnostic{
+		File:     fileName,
+		Lineno:   lineno,
+		Column:   column,
+		Severity: severity,
+		Message:  message,
+		Snippet:  snippet,
+	}
+}
+
+// String formats the diagnostic in compiler style:
+//
+//	file:line:col: error: message
+//	    snippet
+//	    ^
+func (d *Diagnostic) String() string {
+	ret := fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Lineno, d.Column, d.Severity, d.Message)
+	if d.Snippet != "" {
+		// keep tabs so that the caret lines up with the snippet
+		caret := ""
+		for i, c := range d.Snippet {
+			if i >= d.Column-1 {
+				break
+			}
+			if c == '\t' {
+				caret += "\t"
+			} else {
+				caret += " "
+			}
+		}
+		ret += "\n    " + d.Snippet + "\n    " + caret + "^"
+	}
+	return ret
+}
+
+// Error is returned when the def, config or source files have any error, the diagnostics are sorted by position
+type Error struct {
+	Diagnostics []*Diagnostic
+	// Err is the failure found after the diagnostics, eg. the explained line is not in any block, nil when there is none
+	Err error
+}
+
+func newError(diagnostics []*Diagnostic, err error) *Error {
+	ret := &Error{Diagnostics: append([]*Diagnostic{}, diagnostics...), Err: err}
+	sortDiagnostics(ret.Diagnostics)
+	return ret
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	if len(e.Diagnostics) > 0 {
+		lines = append(lines, formatDiagnostics(e.Diagnostics))
+	}
+	if e.Err != nil {
+		lines = append(lines, e.Err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ErrorCount is the number of diagnostics that are errors
+func (e *Error) ErrorCount() int {
+	return countDiagnosticErrors(e.Diagnostics)
+}
+
+func (d *Diagnostic) isError() bool {
+	return d.Severity == SEVERITY_ERROR
+}
+
+func hasDiagnosticError(diagnostics []*Diagnostic) bool {
+	return countDiagnosticErrors(diagnostics) > 0
+}
+
+func countDiagnosticErrors(diagnostics []*Diagnostic) int {
+	count := 0
+	for _, v := range diagnostics {
+		if v.isError() {
+			count++
+		}
+	}
+	return count
+}
+
+// sortDiagnostics sorts diagnostics by position, files are kept in the order they are first reported
+func sortDiagnostics(diagnostics []*Diagnostic) {
+	fileOrder := map[string]int{}
+	for _, v := range diagnostics {
+		if _, ok := fileOrder[v.File]; !ok {
+			fileOrder[v.File] = len(fileOrder)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return fileOrder[a.File] < fileOrder[b.File]
+		}
+		if a.Lineno != b.Lineno {
+			return a.Lineno < b.Lineno
+		}
+		return a.Column < b.Column
+	})
+}
+
+func formatDiagnostics(diagnostics []*Diagnostic) string {
+	lines := make([]string, 0, len(diagnostics))
+	for _, v := range diagnostics {
+		lines = append(lines, v.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,228 @@
+package soscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// value is the result of expression evaluation
+type value struct {
+	valType string // STRING, INT, FLOAT, VERSION or BOOL
+	text    string // string value without quotes
+}
+
+func boolValue(b bool) value {
+	return value{valType: "BOOL", text: strconv.FormatBool(b)}
+}
+
+// literalValue converts the token text of a const value to value
+func literalValue(valType string, text string) value {
+	if (valType == "STRING" || valType == "VERSION") && len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		if s, err := strconv.Unquote(text); err == nil {
+			text = s
+		} else {
+			text = text[1 : len(text)-1]
+		}
+	}
+	return value{valType: valType, text: text}
+}
+
+// numberType is the type of number literal, INT or FLOAT
+func numberType(text string) string {
+	if strings.Contains(text, ".") {
+		return "FLOAT"
+	}
+	return "INT"
+}
+
+func (v value) isNumber() bool {
+	return v.valType == "INT" || v.valType == "FLOAT"
+}
+
+func (v value) isTrue() bool {
+	return v.valType == "BOOL" && v.text == "true"
+}
+
+func (v value) String() string {
+	if v.valType == "STRING" || v.valType == "VERSION" {
+		return strconv.Quote(v.text)
+	}
+	return v.text
+}
+
+// evalEnv resolves the variables and macros used in expressions
+type evalEnv interface {
+	lookup(name string) (value, bool)
+	macro(name string) *macro
+}
+
+// evalError is an error found in evaluation, token is where the error is
+type evalError struct {
+	token   *token
+	message string
+}
+
+func (e *evalError) Error() string {
+	return e.message
+}
+
+func newEvalError(expr exprNode, format string, a ...interface{}) *evalError {
+	return &evalError{token: expr.exprToken(), message: fmt.Sprintf(format, a...)}
+}
+
+// evalExpr evaluates the expression with the variables of env.
+// Both operands of && and || are evaluated, so that errors are reported no matter what the config is.
+func evalExpr(expr exprNode, env evalEnv) (value, *evalError) {
+	switch e := expr.(type) {
+	case *identExpr:
+		val, ok := env.lookup(e.name)
+		if !ok {
+			if m := env.macro(e.name); m != nil {
+				return evalMacro(e, m, nil, env)
+			}
+			return value{}, newEvalError(e, "variable %s is not defined", e.name)
+		}
+		return val, nil
+	case *literalExpr:
+		return literalValue(e.valType, e.text), nil
+	case *parenExpr:
+		return evalExpr(e.inner, env)
+	case *unaryExpr:
+		val, err := evalExpr(e.operand, env)
+		if err != nil {
+			return value{}, err
+		}
+		switch e.op {
+		case token_keyword_not:
+			if val.valType != "BOOL" {
+				return value{}, newEvalError(e.operand, "operand of %s is %s, but BOOL is expected", e.token.text, val.valType)
+			}
+			return boolValue(!val.isTrue()), nil
+		}
+		return value{}, newEvalError(e, "unknown operator %s", e.token.text)
+	case *binaryExpr:
+		return evalBinaryExpr(e, env)
+	case *inExpr:
+		return evalInExpr(e, env)
+	case *callExpr:
+		m := env.macro(e.name)
+		if m == nil {
+			return value{}, newEvalError(e, "function %s is not defined", e.name)
+		}
+		return evalMacro(e, m, e.args, env)
+	}
+	return value{}, newEvalError(expr, "unknown expression %s", expr.String())
+}
+
+func evalBinaryExpr(e *binaryExpr, env evalEnv) (value, *evalError) {
+	left, err := evalExpr(e.left, env)
+	if err != nil {
+		return value{}, err
+	}
+	right, err := evalExpr(e.right, env)
+	if err != nil {
+		return value{}, err
+	}
+	switch e.op {
+	case token_keyword_and, token_keyword_or:
+		if left.valType != "BOOL" {
+			return value{}, newEvalError(e.left, "left operand of %s is %s, but BOOL is expected", e.token.text, left.valType)
+		}
+		if right.valType != "BOOL" {
+			return value{}, newEvalError(e.right, "right operand of %s is %s, but BOOL is expected", e.token.text, right.valType)
+		}
+		if e.op == token_keyword_and {
+			return boolValue(left.isTrue() && right.isTrue()), nil
+		}
+		return boolValue(left.isTrue() || right.isTrue()), nil
+	case token_equal, token_not_equal:
+		var equal bool
+		if left.valType == "BOOL" && right.valType == "BOOL" {
+			equal = left.text == right.text
+		} else {
+			ret, err := compareValues(e, left, right)
+			if err != nil {
+				return value{}, err
+			}
+			equal = ret == 0
+		}
+		return boolValue(equal == (e.op == token_equal)), nil
+	case token_great, token_less, token_great_equal, token_less_equal:
+		ret, err := compareValues(e, left, right)
+		if err != nil {
+			return value{}, err
+		}
+		switch e.op {
+		case token_great:
+			return boolValue(ret > 0), nil
+		case token_less:
+			return boolValue(ret < 0), nil
+		case token_great_equal:
+			return boolValue(ret >= 0), nil
+		}
+		return boolValue(ret <= 0), nil
+	}
+	return value{}, newEvalError(e, "unknown operator %s", e.token.text)
+}
+
+// evalInExpr compares the value with every item of the list by ==, all items are evaluated so that errors are reported
+func evalInExpr(e *inExpr, env evalEnv) (value, *evalError) {
+	found := false
+	for _, item := range e.list {
+		val, err := evalBinaryExpr(&binaryExpr{token: e.token, op: token_equal, left: e.left, right: item}, env)
+		if err != nil {
+			return value{}, err
+		}
+		found = found || val.isTrue()
+	}
+	return boolValue(found != e.not), nil
+}
+
+// compareValues returns -1, 0 or 1 like strings.Compare.
+// A string compared with a version is taken as a version, so that "1.0.10" > "1.0.9".
+func compareValues(e *binaryExpr, left value, right value) (int, *evalError) {
+	if left.valType == "VERSION" || right.valType == "VERSION" {
+		va, err := versionOperand(e.left, left)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := versionOperand(e.right, right)
+		if err != nil {
+			return 0, err
+		}
+		return va.compare(vb), nil
+	}
+	// int and float are compared as numbers
+	if left.isNumber() && right.isNumber() {
+		a, errA := strconv.ParseFloat(left.text, 64)
+		b, errB := strconv.ParseFloat(right.text, 64)
+		if errA != nil || errB != nil {
+			return 0, newEvalError(e, "invalid number")
+		}
+		if a < b {
+			return -1, nil
+		} else if a > b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if left.valType != right.valType {
+		return 0, newEvalError(e, "can not compare %s with %s", left.valType, right.valType)
+	}
+	if left.valType == "STRING" {
+		return strings.Compare(left.text, right.text), nil
+	}
+	return 0, newEvalError(e, "%s values can not be ordered by %s", left.valType, e.token.text)
+}
+
+func versionOperand(expr exprNode, val value) (*version, *evalError) {
+	if val.valType != "VERSION" && val.valType != "STRING" {
+		return nil, newEvalError(expr, "can not compare %s with VERSION", val.valType)
+	}
+	ret, err := parseVersion(val.text)
+	if err != nil {
+		return nil, newEvalError(expr, "%s", err.Error())
+	}
+	return ret, nil
+}
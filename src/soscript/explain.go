@@ -0,0 +1,303 @@
+package soscript
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// explainer prints why the lines of a block are or are not selected with the current config
+type explainer struct {
+	parser *parser
+	w      io.Writer
+	// variables that decide the explained conditions, in the order they are found
+	varNameList []string
+	varSet      map[string]*varDecl
+}
+
+func newExplainer(parser *parser, w io.Writer) *explainer {
+	return &explainer{parser: parser, w: w, varSet: make(map[string]*varDecl, 0)}
+}
+
+// explainLine explains the soscript block or the if block at lineno of the parsed source,
+// the condition of the line is explained when lineno is a <line> or a tag of if block, otherwise all the conditions of the block
+func (e *explainer) explainLine(lineno int) error {
+	for _, soscript := range e.parser.soscriptList {
+		if lineno >= soscript.startLineno && lineno <= soscript.endLineno {
+			e.explainSoscript(soscript, lineno)
+			return nil
+		}
+	}
+	for _, block := range e.parser.ifBlockList {
+		if lineno >= block.startLineno && lineno <= block.endLineno {
+			e.explainIfBlock(block, lineno)
+			return nil
+		}
+	}
+	return fmt.Errorf("line %d of %s is not in any soscript block or if block", lineno, e.parser.sourceLexer.fileName)
+}
+
+func (e *explainer) explainSoscript(soscript *soscriptBlock, lineno int) {
+	env := &soscriptEnv{parser: e.parser, soscript: soscript}
+	explainAll := true
+	for _, line := range soscript.lineList {
+		if line.token.lineno == lineno {
+			explainAll = false
+		}
+	}
+	fmt.Fprintf(e.w, "soscript block %s:%d-%d\n", e.parser.sourceLexer.fileName, soscript.startLineno, soscript.endLineno)
+	var selectedLine *soscriptLine
+	for _, line := range soscript.lineList {
+		if line.selected && selectedLine == nil {
+			selectedLine = line
+		}
+		if explainAll || line.token.lineno == lineno {
+			e.explainCondition(line.token, line.expr, env, e.lineResult(soscript, line))
+		}
+	}
+	if selectedLine != nil {
+		fmt.Fprintf(e.w, "selected: line %d, code:\n", selectedLine.token.lineno)
+		for _, v := range strings.Split(soscript.code, "\n") {
+			fmt.Fprintf(e.w, "    %s\n", v)
+		}
+	} else {
+		fmt.Fprintf(e.w, "selected: none, the default code of line %d-%d is kept\n", soscript.defaultStartLineno+1, soscript.defaultEndLineno-1)
+	}
+	e.explainVars()
+}
+
+// lineResult describes the result of a line in block
+func (e *explainer) lineResult(soscript *soscriptBlock, line *soscriptLine) string {
+	if line.lineType == line_type_assign {
+		return fmt.Sprintf("%s = %s", line.name, valueDesc(line.val))
+	}
+	if line.selected {
+		return "selected"
+	}
+	if line.val.valType == "" {
+		return "error"
+	}
+	if !line.val.isTrue() {
+		return "not selected, the condition is false"
+	}
+	// the condition is true, but an earlier branch of the chain is selected
+	for i := len(soscript.lineList) - 1; i >= 0; i-- {
+		prev := soscript.lineList[i]
+		if prev.token.lineno < line.token.lineno && prev.selected {
+			return fmt.Sprintf("not selected, line %d of the chain is selected before it", prev.token.lineno)
+		}
+	}
+	return "not selected"
+}
+
+func (e *explainer) explainIfBlock(block *ifBlock, lineno int) {
+	env := &soscriptEnv{parser: e.parser}
+	explainAll := true
+	for _, branch := range block.branchList {
+		if branch.token.lineno == lineno {
+			explainAll = false
+		}
+	}
+	fmt.Fprintf(e.w, "if block %s:%d-%d\n", e.parser.sourceLexer.fileName, block.startLineno, block.endLineno)
+	for _, branch := range block.branchList {
+		if !explainAll && branch.token.lineno != lineno {
+			continue
+		}
+		result := "not selected, the condition is false"
+		if branch.selected {
+			result = "selected"
+		} else if branch.val.valType == "" {
+			result = "error"
+		} else if branch.val.isTrue() {
+			result = fmt.Sprintf("not selected, line %d is selected before it", block.selected.token.lineno)
+		}
+		e.explainCondition(branch.token, branch.expr, env, result)
+	}
+	if block.selected != nil {
+		fmt.Fprintf(e.w, "selected: line %d\n", block.selected.token.lineno)
+	} else {
+		fmt.Fprintln(e.w, "selected: none, the code of all branches is removed")
+	}
+	e.explainVars()
+}
+
+// explainCondition prints the condition tree, every subexpression is annotated with its value
+func (e *explainer) explainCondition(token *token, expr exprNode, env *soscriptEnv, result string) {
+	fmt.Fprintf(e.w, "line %d: %s\n", token.lineno, strings.TrimSpace(token.lexer.line(token.lineno)))
+	if expr != nil {
+		e.explainExpr(expr, env, 1, true)
+	}
+	fmt.Fprintf(e.w, "  => %s\n", result)
+}
+
+// explainExpr prints expr and its subexpressions. decides tells whether the value of expr decides the condition,
+// the operand after && or || does not when the operand before it decides the result
+func (e *explainer) explainExpr(expr exprNode, env *soscriptEnv, depth int, decides bool) {
+	indent := strings.Repeat("  ", depth)
+	switch v := expr.(type) {
+	case *parenExpr:
+		e.explainExpr(v.inner, env, depth, decides)
+		return
+	case *literalExpr:
+		return
+	case *identExpr:
+		varDeclare := env.find(v.name)
+		if m := env.macro(v.name); varDeclare == nil && m != nil {
+			e.explainMacro(v, m, nil, env, depth, decides)
+			return
+		}
+		if varDeclare == nil {
+			fmt.Fprintf(e.w, "%s%s: not defined\n", indent, v.name)
+			return
+		}
+		if decides {
+			e.addVar(varDeclare)
+		}
+		val, _ := evalExpr(v, env)
+		fmt.Fprintf(e.w, "%s%s: %s (%s)\n", indent, v.name, valueDesc(val), varOrigin(varDeclare))
+		return
+	case *callExpr:
+		if m := env.macro(v.name); m != nil {
+			e.explainMacro(v, m, v.args, env, depth, decides)
+			return
+		}
+	}
+	val, err := evalExpr(expr, env)
+	if err != nil {
+		fmt.Fprintf(e.w, "%s%s: error: %s\n", indent, expr.String(), err.message)
+	} else {
+		fmt.Fprintf(e.w, "%s%s: %s\n", indent, expr.String(), valueDesc(val))
+	}
+	switch v := expr.(type) {
+	case *binaryExpr:
+		e.explainExpr(v.left, env, depth+1, decides)
+		e.explainExpr(v.right, env, depth+1, decides && !shortCircuits(v, env))
+	case *unaryExpr:
+		e.explainExpr(v.operand, env, depth+1, decides)
+	case *callExpr:
+		for _, arg := range v.args {
+			e.explainExpr(arg, env, depth+1, decides)
+		}
+	case *inExpr:
+		e.explainExpr(v.left, env, depth+1, decides)
+		for _, item := range v.list {
+			e.explainExpr(item, env, depth+1, decides)
+		}
+	}
+}
+
+// explainMacro prints the value of macro and explains its expansion
+func (e *explainer) explainMacro(expr exprNode, m *macro, args []exprNode, env *soscriptEnv, depth int, decides bool) {
+	indent := strings.Repeat("  ", depth)
+	val, err := evalExpr(expr, env)
+	if err != nil {
+		fmt.Fprintf(e.w, "%s%s: error: %s\n", indent, expr.String(), err.message)
+	} else {
+		fmt.Fprintf(e.w, "%s%s: %s (macro %s:%d)\n", indent, expr.String(), valueDesc(val), m.token.lexer.fileName, m.token.lineno)
+	}
+	if m.invalid || len(args) != len(m.params) {
+		return
+	}
+	expansion := m.expand(args)
+	fmt.Fprintf(e.w, "%s  expands to %s\n", indent, expansion.String())
+	e.explainExpr(expansion, env, depth+1, decides)
+}
+
+// shortCircuits tells whether the left operand of && or || decides the result, so that the right operand does not,
+// an invalid left operand decides the error
+func shortCircuits(expr *binaryExpr, env *soscriptEnv) bool {
+	if expr.op != token_keyword_and && expr.op != token_keyword_or {
+		return false
+	}
+	left, err := evalExpr(expr.left, env)
+	if err != nil || left.valType != "BOOL" {
+		return true
+	}
+	return left.isTrue() == (expr.op == token_keyword_or)
+}
+
+func (e *explainer) addVar(varDeclare *varDecl) {
+	if _, ok := e.varSet[varDeclare.name]; ok {
+		return
+	}
+	e.varSet[varDeclare.name] = varDeclare
+	e.varNameList = append(e.varNameList, varDeclare.name)
+}
+
+// varOrigin tells the position where the value of variable is assigned
+func varOrigin(varDeclare *varDecl) string {
+	token := varDeclare.valToken
+	if token == nil || token.lexer == nil {
+		return "not assigned"
+	}
+	return fmt.Sprintf("%s:%d", token.lexer.fileName, token.lineno)
+}
+
+// explainVars prints the assignments of the variables that decide the explained conditions
+func (e *explainer) explainVars() {
+	if len(e.varNameList) == 0 {
+		return
+	}
+	fmt.Fprintln(e.w, "decided by:")
+	for _, name := range e.varNameList {
+		varDeclare := e.varSet[name]
+		if varDeclare.valToken == nil {
+			fmt.Fprintf(e.w, "    %s is not assigned\n", name)
+			continue
+		}
+		fmt.Fprintf(e.w, "    %s\n", varDeclare.source())
+	}
+	e.varNameList = nil
+	e.varSet = make(map[string]*varDecl, 0)
+}
+
+// writeEffectiveConfig prints the value of every global variable after all config layers, with where it is assigned
+func writeEffectiveConfig(parser *parser, w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range parser.varNameList {
+		varDeclare := parser.varDeclareSet[name]
+		val := "-"
+		if varDeclare.currVal != "" {
+			val = varDeclare.currVal
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, val, varDeclare.source())
+	}
+	tw.Flush()
+}
+
+// writeVarDefs prints the declared variables with their values, defaults and descriptions, and the declared macros
+func writeVarDefs(parser *parser, w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUES\tDEFAULT\tREQUIRED\tDESCRIPTION")
+	for _, name := range parser.varNameList {
+		varDeclare := parser.varDeclareSet[name]
+		vals := "any"
+		if !varDeclare.anyVal {
+			vals = strings.Join(varDeclare.valList, ", ")
+		}
+		defaultVal := "-"
+		if varDeclare.defaultToken != nil {
+			defaultVal = varDeclare.defaultToken.text
+		}
+		required := "optional"
+		if varDeclare.required {
+			required = "required"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", name, strings.ToLower(varDeclare.varType), vals, defaultVal, required, varDeclare.desc)
+	}
+	tw.Flush()
+	if len(parser.macroNameList) > 0 {
+		fmt.Fprintln(w, "\nMACROS")
+	}
+	for _, name := range parser.macroNameList {
+		fmt.Fprintln(w, parser.macroSet[name].String())
+	}
+}
+
+func valueDesc(val value) string {
+	if val.valType == "" {
+		return "error"
+	}
+	return val.String()
+}
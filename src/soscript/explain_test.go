@@ -0,0 +1,87 @@
+package soscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const explainDefs = testDefs + `
+enableLog: bool
+	default false
+`
+
+const explainSource = `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(platform == "ios" || enableLog && level > 3) print(<code> int a = 1; </code>) </line>
+// <line> elif(platform == "android") print(<code> int a = 2; </code>) </line>
+// </soscript>
+`
+
+func explainTest(t *testing.T, config string, lineno int) string {
+	t.Helper()
+	cfg := loadTestConfig(t, explainDefs, config)
+	var out bytes.Buffer
+	if err := Explain(&out, strings.NewReader(explainSource), lineno, cfg, &Options{Name: "src.java"}); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// --line explains the condition of the line, the operands after && and || are not listed when the operands before them decide
+func TestExplainLine(t *testing.T) {
+	tests := []struct {
+		config string
+		output string
+	}{
+		{`platform = "ios"`, `soscript block src.java:1-7
+line 5: // <line> if(platform == "ios" || enableLog && level > 3) print(<code> int a = 1; </code>) </line>
+  platform == "ios" || enableLog && level > 3: true
+    platform == "ios": true
+      platform: "ios" (conf.ss:1)
+    enableLog && level > 3: false
+      enableLog: false (def.ss:8)
+      level > 3: false
+        level: 3 (def.ss:5)
+  => selected
+selected: line 5, code:
+    int a = 1;
+decided by:
+    conf.ss:1: platform = "ios"
+`},
+		{"platform = \"android\"\nlevel = 4", `soscript block src.java:1-7
+line 5: // <line> if(platform == "ios" || enableLog && level > 3) print(<code> int a = 1; </code>) </line>
+  platform == "ios" || enableLog && level > 3: false
+    platform == "ios": false
+      platform: "android" (conf.ss:1)
+    enableLog && level > 3: false
+      enableLog: false (def.ss:8)
+      level > 3: true
+        level: 4 (conf.ss:2)
+  => not selected, the condition is false
+selected: line 6, code:
+    int a = 2;
+decided by:
+    conf.ss:1: platform = "android"
+    def.ss:8: default false
+`},
+	}
+	for _, test := range tests {
+		if got := explainTest(t, test.config, 5); got != test.output {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.config, got, test.output)
+		}
+	}
+}
+
+// a line in the block but not on a <line> explains all the lines of the block
+func TestExplainBlock(t *testing.T) {
+	got := explainTest(t, "platform = \"pc\"\nenableLog = true\nlevel = 5", 3)
+	for _, want := range []string{"line 5: ", "line 6: ", "  => selected\n", "  => not selected, the condition is false\n",
+		"decided by:\n    conf.ss:1: platform = \"pc\"\n    conf.ss:2: enableLog = true\n    conf.ss:3: level = 5\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant %q in it", got, want)
+		}
+	}
+}
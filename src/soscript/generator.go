@@ -0,0 +1,194 @@
+package soscript
+
+import (
+	"strings"
+)
+
+type sourceGen struct {
+	inPlace       bool
+	parser        *parser
+	output        string
+	changedBlocks int
+}
+
+func newSourceGen(inPlace bool, parser *parser) *sourceGen {
+	ret := &sourceGen{
+		inPlace: inPlace,
+		parser:  parser,
+		output:  "",
+	}
+	return ret
+}
+
+// gen builds the output of the parsed source, the caller writes it
+func (g *sourceGen) gen() {
+	lines := g.parser.sourceLexer.lines
+	soscriptSet := make(map[int]*soscriptBlock, len(g.parser.soscriptList))
+	for _, v := range g.parser.soscriptList {
+		soscriptSet[v.startLineno] = v
+	}
+	ifBlockSet := make(map[int]*ifBlock, len(g.parser.ifBlockList))
+	for _, v := range g.parser.ifBlockList {
+		ifBlockSet[v.startLineno] = v
+	}
+	lexer := g.parser.sourceLexer
+	var output strings.Builder
+	for i := 0; i < len(lines); i++ {
+		lineno := i + 1
+		var blockLines []string
+		endLineno := 0
+		if soscript, ok := soscriptSet[lineno]; ok {
+			blockLines = g.gen_soscript_block(soscript)
+			endLineno = soscript.endLineno
+		} else if block, ok := ifBlockSet[lineno]; ok {
+			blockLines = g.gen_if_block(block)
+			endLineno = block.endLineno
+		} else {
+			output.WriteString(lines[i] + lexer.lineBreak(lineno))
+			continue
+		}
+		if !equalLines(blockLines, lines[lineno-1:endLineno]) {
+			g.changedBlocks++
+		}
+		for k, v := range blockLines {
+			output.WriteString(v + lexer.blockLineBreak(lineno, endLineno, k, len(blockLines)))
+		}
+		i = endLineno - 1
+	}
+	g.output = output.String()
+}
+
+// genStream parses and compiles the source of streaming lexer block by block, the lexer copies the text out of blocks to its output.
+// Every block is written as soon as it is parsed, so the output is incomplete when the parser has any error.
+// It returns the number of blocks and the first error of writing the output
+func (g *sourceGen) genStream(lexer *lexer) (int, error) {
+	blocks := 0
+	g.parser.parseSourceStream(lexer, func(soscript *soscriptBlock, block *ifBlock) {
+		blocks++
+		if g.parser.hasError() {
+			return
+		}
+		var blockLines []string
+		var startLineno, endLineno int
+		if soscript != nil {
+			blockLines = g.gen_soscript_block(soscript)
+			startLineno, endLineno = soscript.startLineno, soscript.endLineno
+		} else {
+			blockLines = g.gen_if_block(block)
+			startLineno, endLineno = block.startLineno, block.endLineno
+		}
+		if !equalLines(blockLines, lexer.sliceLines(startLineno-1, endLineno)) {
+			g.changedBlocks++
+		}
+		for k, v := range blockLines {
+			lexer.writeLine(v, lexer.blockLineBreak(startLineno, endLineno, k, len(blockLines)))
+		}
+	})
+	return blocks, lexer.writeEnd()
+}
+
+func (g *sourceGen) gen_soscript_block(soscript *soscriptBlock) []string {
+	if !g.inPlace {
+		// replace the whole <soscript> block with the selected code
+		return g.gen_soscript(soscript)
+	}
+	// keep the soscript markers, only rewrite the default code
+	lexer := g.parser.sourceLexer
+	var ret []string
+	ret = append(ret, lexer.sliceLines(soscript.startLineno-1, soscript.defaultStartLineno)...)
+	ret = append(ret, g.gen_default(soscript)...)
+	ret = append(ret, lexer.sliceLines(soscript.defaultEndLineno-1, soscript.endLineno)...)
+	return ret
+}
+
+func (g *sourceGen) gen_soscript(soscript *soscriptBlock) []string {
+	if soscript.matched {
+		startLine := g.parser.sourceLexer.line(soscript.startLineno)
+		return codeLines(lineIndent(startLine), soscript.code)
+	}
+	// no line matched, keep the default code
+	return g.defaultLines(soscript)
+}
+
+// gen_default generates the code between <default> and </default> for in-place compile,
+// the original default code is saved in <origin> comments so that it can be restored later
+func (g *sourceGen) gen_default(soscript *soscriptBlock) []string {
+	defaultLines := g.defaultLines(soscript)
+	if !soscript.matched {
+		return defaultLines
+	}
+	startLine := g.parser.sourceLexer.line(soscript.defaultStartLineno)
+	commentPrefix := startLine[:strings.Index(startLine, "<default>")]
+	commentSuffix := ""
+	if suffix := g.parser.sourceLexer.comment.suffix; suffix != "" {
+		commentSuffix = " " + suffix
+	}
+	ret := codeLines(lineIndent(startLine), soscript.code)
+	for _, v := range defaultLines {
+		ret = append(ret, commentPrefix+"<origin>"+v+"</origin>"+commentSuffix)
+	}
+	return ret
+}
+
+func (g *sourceGen) defaultLines(soscript *soscriptBlock) []string {
+	if len(soscript.originLines) > 0 {
+		return soscript.originLines
+	}
+	return g.parser.sourceLexer.sliceLines(soscript.defaultStartLineno, soscript.defaultEndLineno-1)
+}
+
+// gen_if_block keeps the code of the selected branch. For in-place compile, the tags are kept
+// and the code of other branches is commented out with disabled_mark
+func (g *sourceGen) gen_if_block(block *ifBlock) []string {
+	lexer := g.parser.sourceLexer
+	comment := lexer.comment
+	ret := make([]string, 0, block.endLineno-block.startLineno+1)
+	for i, branch := range block.branchList {
+		tagLine := lexer.line(branch.token.lineno)
+		codeEndLineno := block.endLineno
+		if i+1 < len(block.branchList) {
+			codeEndLineno = block.branchList[i+1].token.lineno
+		}
+		if g.inPlace {
+			ret = append(ret, tagLine)
+		}
+		for _, v := range lexer.sliceLines(branch.token.lineno, codeEndLineno-1) {
+			if branch.selected {
+				ret = append(ret, comment.enable(v))
+			} else if g.inPlace {
+				ret = append(ret, comment.disable(v))
+			}
+		}
+	}
+	if g.inPlace {
+		ret = append(ret, lexer.line(block.endLineno))
+	}
+	return ret
+}
+
+// codeLines splits the code to lines with indent
+func codeLines(indent string, code string) []string {
+	ret := strings.Split(code, "\n")
+	for i, v := range ret {
+		if v != "" {
+			ret[i] = indent + v
+		}
+	}
+	return ret
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lineIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
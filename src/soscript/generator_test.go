@@ -0,0 +1,100 @@
+package soscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const inPlaceSource = `class A {
+    // <soscript>
+    // <default>
+    int a = 0;
+    int b = 0;
+    // </default>
+    // <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+    // <line> elif(platform == "ios") print(<code> int a = 2; </code>) </line>
+    // </soscript>
+
+    // <if cond="platform == "android"">
+    initGooglePlay();
+    // <elif cond="platform == "ios"">
+    initStoreKit();
+    // <else>
+    initWeb();
+    // </if>
+}
+`
+
+// compileInPlace compiles the source in place with the platform
+func compileInPlace(t *testing.T, source string, platform string) string {
+	t.Helper()
+	cfg := loadTestConfig(t, testDefs, `platform = "`+platform+`"`)
+	var out bytes.Buffer
+	if err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "A.java", InPlace: true}); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestInPlace(t *testing.T) {
+	want := map[string]string{
+		"android": `class A {
+    // <soscript>
+    // <default>
+    int a = 1;
+    // <origin>    int a = 0;</origin>
+    // <origin>    int b = 0;</origin>
+    // </default>
+    // <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+    // <line> elif(platform == "ios") print(<code> int a = 2; </code>) </line>
+    // </soscript>
+
+    // <if cond="platform == "android"">
+    initGooglePlay();
+    // <elif cond="platform == "ios"">
+    //~initStoreKit();
+    // <else>
+    //~initWeb();
+    // </if>
+}
+`,
+		"ios": `class A {
+    // <soscript>
+    // <default>
+    int a = 2;
+    // <origin>    int a = 0;</origin>
+    // <origin>    int b = 0;</origin>
+    // </default>
+    // <line> if(platform == "android") print(<code> int a = 1; </code>) </line>
+    // <line> elif(platform == "ios") print(<code> int a = 2; </code>) </line>
+    // </soscript>
+
+    // <if cond="platform == "android"">
+    //~initGooglePlay();
+    // <elif cond="platform == "ios"">
+    initStoreKit();
+    // <else>
+    //~initWeb();
+    // </if>
+}
+`,
+		"pc": strings.NewReplacer("    initGooglePlay();", "    //~initGooglePlay();", "    initStoreKit();", "    //~initStoreKit();").Replace(inPlaceSource),
+	}
+	for _, platform := range []string{"android", "ios", "pc"} {
+		output := compileInPlace(t, inPlaceSource, platform)
+		if output != want[platform] {
+			t.Errorf("%s: got\n%s\nwant\n%s", platform, output, want[platform])
+		}
+		// compiling the output again with the same config changes nothing
+		if again := compileInPlace(t, output, platform); again != output {
+			t.Errorf("%s: compiled again\n%s\nwant\n%s", platform, again, output)
+		}
+		// the output compiles with any other config the same as the source does
+		for _, other := range []string{"android", "ios", "pc"} {
+			if got := compileInPlace(t, output, other); got != want[other] {
+				t.Errorf("%s then %s: got\n%s\nwant\n%s", platform, other, got, want[other])
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package soscript
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes the files under dir, the names are slash separated
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// the variables of imported def files are declared under their namespaces, the files are found next to the importing file then in -I dirs
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"defs/def.ss": `import "common_def.ss"
+import "shared_def.ss" as base
+level: int[1..5]
+	default 3
+`,
+		"defs/common_def.ss": `platform: {"android", "ios"}
+`,
+		"shared/shared_def.ss": `import "common_def.ss"
+mode: {"debug", "release"}
+`,
+		// found for the import of shared_def.ss, next to it
+		"shared/common_def.ss": `channel: {"google", "huawei"}
+`,
+	})
+	defs, err := LoadDefsFile(filepath.Join(dir, "defs/def.ss"), filepath.Join(dir, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defs.NewConfig()
+	cfg.LoadEnv([]string{"SSC_VAR_BASE__MODE=debug", "SSC_VAR_BASE__COMMON__CHANNEL=google"})
+	cfg.Set(`common.platform="android"`, "base.mode=release")
+	source := `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(common.platform == "android" && base.mode == "release" && base.common.channel == "google" && level == 3) print(<code> int a = 1; </code>) </line>
+// </soscript>
+`
+	var out bytes.Buffer
+	if err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "src.java"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "int a = 1;\n" {
+		t.Errorf("got %q", out.String())
+	}
+	if got := configValues(cfg)["base.mode"]; got != `"release"` {
+		t.Errorf("got base.mode %s, -D should override the environment", got)
+	}
+
+	// without -I, shared_def.ss is not found
+	defs, err = LoadDefsFile(filepath.Join(dir, "defs/def.ss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = defs.Err()
+	if err == nil || !strings.Contains(err.Error(), "cannot find def file shared_def.ss") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a_def.ss":    "import \"b_def.ss\"\nx: {\"1\"}\n",
+		"b_def.ss":    "import \"a_def.ss\"\ny: {\"1\"}\n",
+		"def.ss":      "import \"my-def.ss\"\n",
+		"my-def.ss":   "z: {\"1\"}\n",
+		"twice.ss":    "import \"b_def.ss\" as b\nimport \"c_def.ss\" as b\n",
+		"c_def.ss":    "y: {\"2\"}\n",
+		"self_def.ss": "import \"self_def.ss\"\n",
+	})
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"a_def.ss", "import cycle: " + filepath.Join(dir, "a_def.ss") + " -> " + filepath.Join(dir, "b_def.ss") + " -> " + filepath.Join(dir, "a_def.ss")},
+		{"self_def.ss", "import cycle: "},
+		{"def.ss", `invalid namespace "my-def" of my-def.ss, use import "my-def.ss" as <name>`},
+		{"twice.ss", "variable b.y has been declared"},
+	}
+	for _, test := range tests {
+		defs, err := LoadDefsFile(filepath.Join(dir, test.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := defs.Err(); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.message)
+		}
+	}
+}
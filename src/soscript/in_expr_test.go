@@ -0,0 +1,61 @@
+package soscript
+
+import (
+	"testing"
+)
+
+func inSource(cond string) string {
+	return `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(` + cond + `) print(<code> int a = 1; </code>) </line>
+// </soscript>
+`
+}
+
+func TestInExpr(t *testing.T) {
+	tests := []struct {
+		platform string
+		cond     string
+		selected bool
+	}{
+		{"android", `platform in ("android", "ios")`, true},
+		{"pc", `platform in ("android", "ios")`, false},
+		{"ios", `platform not in ("pc", "h5")`, true},
+		{"h5", `platform not in ("pc", "h5")`, false},
+		{"pc", `platform in ("pc")`, true},
+		{"pc", `level in (1, 3) && level not in (2)`, true},
+		// in binds tighter than && and ||
+		{"pc", `platform in ("h5") || level in (3) && platform not in ("ios")`, true},
+		{"ios", `!(platform in ("android", "ios"))`, false},
+	}
+	for _, test := range tests {
+		output, err := compileTest(t, testDefs, `platform = "`+test.platform+`"`, inSource(test.cond))
+		if err != nil {
+			t.Errorf("%s: %v", test.cond, err)
+			continue
+		}
+		if selected := output == "int a = 1;\n"; selected != test.selected {
+			t.Errorf("%s with %s: got selected %v, want %v", test.cond, test.platform, selected, test.selected)
+		}
+	}
+}
+
+// the literals in the list are checked against the declared values like ==
+func TestInExprErrors(t *testing.T) {
+	tests := []struct {
+		cond        string
+		diagnostics []string
+	}{
+		{`platform in ("android", "andriod")`, []string{`src.java:5: value "andriod" is not declared for platform (platform that the program run on); declared values: "pc", "android", "ios", "h5"; did you mean "android"?`}},
+		{`platform not in ("wechat")`, []string{`src.java:5: value "wechat" is not declared for platform`}},
+		{`level in (2, 9)`, []string{"src.java:5: value 9 is out of range [1..5] of level"}},
+		{`platform in "pc"`, []string{"src.java:5: syntax error: expected '('"}},
+		{`platform in ("pc"`, []string{"src.java:5: syntax error: expected ')'"}},
+	}
+	for _, test := range tests {
+		_, err := compileTest(t, testDefs, `platform = "pc"`, inSource(test.cond))
+		checkDiagnostics(t, err, test.diagnostics)
+	}
+}
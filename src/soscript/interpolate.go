@@ -0,0 +1,115 @@
+package soscript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// var_modes escape the value of <var> for the target language, eg. <var>addr|quoted</var>
+var var_modes = map[string]func(val value) string{
+	"raw":    rawValue,
+	"quoted": quotedValue,
+	"json":   jsonValue,
+}
+
+var var_name_rule = regexp.MustCompile(`^\w+(\.\w+)*$`)
+
+// rawValue is the value as it is, strings are not quoted
+func rawValue(val value) string {
+	return val.text
+}
+
+// quotedValue is a double quoted string literal with C style escapes, which fits most languages
+func quotedValue(val value) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(val.text) + `"`
+}
+
+// jsonValue keeps numbers and bools as they are, other values are JSON strings
+func jsonValue(val value) string {
+	if val.isNumber() || val.valType == "BOOL" {
+		return val.text
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(val.text)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func varModeNames() string {
+	names := make([]string, 0, len(var_modes))
+	for k := range var_modes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// interpolate replaces <var>name</var> and <var>name|mode</var> in the code of line with the values of variables.
+// Every <var> is checked, but the value is only required when the line is selected,
+// so that a config needs not assign the variables used by other branches.
+func (p *parser) interpolate(line *soscriptLine, env *soscriptEnv) string {
+	if line.codeToken == nil {
+		return line.code
+	}
+	code := line.code
+	ret := ""
+	offset := 0
+	for {
+		start := strings.Index(code[offset:], "<var>")
+		if start < 0 {
+			return ret + code[offset:]
+		}
+		start += offset
+		ret += code[offset:start]
+		end := strings.Index(code[start:], "</var>")
+		if end < 0 {
+			p.addError(codeToken(line.codeToken, start), "missing </var>")
+			return ret
+		}
+		end += start
+		offset = end + len("</var>")
+		token := codeToken(line.codeToken, start+len("<var>"))
+		name, mode := code[start+len("<var>"):end], "raw"
+		if idx := strings.Index(name, "|"); idx >= 0 {
+			name, mode = name[:idx], strings.TrimSpace(name[idx+1:])
+		}
+		name = strings.TrimSpace(name)
+		escape, ok := var_modes[mode]
+		if !ok {
+			p.addError(token, fmt.Sprintf("unknown mode %s of <var>, supported modes: %s", mode, varModeNames()))
+			continue
+		}
+		if !var_name_rule.MatchString(name) {
+			p.addError(token, fmt.Sprintf("invalid variable name %q in <var>", name))
+			continue
+		}
+		varDeclare := env.find(name)
+		if varDeclare == nil {
+			p.addError(token, fmt.Sprintf("variable %s is not defined", name))
+			continue
+		}
+		if !line.selected {
+			continue
+		}
+		if varDeclare.currVal == "" {
+			p.addError(token, fmt.Sprintf("variable %s is not assigned in config", name))
+			continue
+		}
+		ret += escape(literalValue(varDeclare.varType, varDeclare.currVal))
+	}
+}
+
+// codeToken locates the error at offset of the code, multi-line code is located at <code>
+func codeToken(token *token, offset int) *token {
+	if strings.Contains(token.text, "\n") {
+		return token
+	}
+	ret := *token
+	ret.column += offset
+	return &ret
+}
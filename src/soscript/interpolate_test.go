@@ -0,0 +1,78 @@
+package soscript
+
+import (
+	"testing"
+)
+
+const varDefs = testDefs + `
+addr: string
+	optional
+
+enableLog: bool
+	default true
+
+ratio: float
+	default 0.5
+`
+
+// varSource prints the code with <var> when platform is android
+func varSource(code string) string {
+	return `// <soscript>
+// <default>
+x = 0;
+// </default>
+// <line> if(platform == "android") print(<code>` + code + `</code>) </line>
+// </soscript>
+`
+}
+
+func TestInterpolate(t *testing.T) {
+	config := "platform = \"android\"\naddr = \"a \\\"b\\\" \\\\ c\\td\""
+	tests := []struct {
+		code   string
+		output string
+	}{
+		{`x = <var>addr</var>;`, `x = a "b" \ c	d;`},
+		{`x = <var>addr|raw</var>;`, `x = a "b" \ c	d;`},
+		{`x = <var> addr | quoted </var>;`, `x = "a \"b\" \\ c\td";`},
+		{`x = <var>addr|json</var>;`, `x = "a \"b\" \\ c\td";`},
+		{`x = <var>level|json</var> + <var>ratio|json</var>; y = <var>enableLog|json</var>;`, `x = 3 + 0.5; y = true;`},
+		{`x = <var>level|quoted</var>; y = <var>platform</var>;`, `x = "3"; y = android;`},
+	}
+	for _, test := range tests {
+		output, err := compileTest(t, varDefs, config, varSource(test.code))
+		if err != nil {
+			t.Fatalf("%s: %v", test.code, err)
+		}
+		if want := test.output + "\n"; output != want {
+			t.Errorf("%s: got %q, want %q", test.code, output, want)
+		}
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		platform    string
+		code        string
+		diagnostics []string
+	}{
+		{"android", `x = <var>addr;`, []string{"src.java:5: missing </var>"}},
+		{"android", `x = <var>addr|html</var>;`, []string{"src.java:5: unknown mode html of <var>, supported modes: json, quoted, raw"}},
+		{"android", `x = <var>a-b</var>;`, []string{`src.java:5: invalid variable name "a-b" in <var>`}},
+		{"android", `x = <var>host</var>;`, []string{"src.java:5: variable host is not defined"}},
+		{"android", `x = <var>addr</var>;`, []string{"src.java:5: variable addr is not assigned in config"}},
+		// the names are checked in every line, the values only in the selected line
+		{"ios", `x = <var>host</var>;`, []string{"src.java:5: variable host is not defined"}},
+		{"ios", `x = <var>addr</var>;`, nil},
+	}
+	for _, test := range tests {
+		_, err := compileTest(t, varDefs, `platform = "`+test.platform+`"`, varSource(test.code))
+		if test.diagnostics == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.code, err)
+			}
+			continue
+		}
+		checkDiagnostics(t, err, test.diagnostics)
+	}
+}
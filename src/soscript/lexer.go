@@ -0,0 +1,637 @@
+package soscript
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+const (
+	token_min     = iota
+	token_comment // //
+	token_number
+	token_string
+	token_define         // :=
+	token_equal          // ==
+	token_not_equal      // !=
+	token_great_equal    // >=
+	token_less_equal     // <=
+	token_great          // >
+	token_less           // <
+	token_assign         // =
+	token_comma          // ,
+	token_colon          // :
+	token_brace_left     // {
+	token_brace_right    // }
+	token_brackets_left  // (
+	token_brackets_right // )
+	token_square_left    // [
+	token_square_right   // ]
+	token_range          // ..
+	token_quote          // "
+	token_keyword_if     // if
+	token_keyword_elif   // elif
+	token_keyword_else   // else
+	token_keyword_print  // print
+	token_keyword_and    // &&
+	token_keyword_or     // ||
+	token_keyword_not    // !
+	token_keyword_in     // in
+	token_keyword_not_in // not in
+
+	token_symbol
+
+	token_code   // text between <code> and </code>
+	token_origin // text between <origin> and </origin>
+	token_bare   // unquoted value of JSON, YAML, TOML and .env config
+
+	tag_soscript_start
+	tag_soscript_end
+
+	tag_default_start
+	tag_default_end
+
+	tag_line_start
+	tag_line_end
+
+	tag_code_start
+	tag_code_end
+
+	tag_var_start
+	tag_var_end
+
+	tag_origin_start
+	tag_origin_end
+
+	tag_if_start   // <if cond="...">
+	tag_elif_start // <elif cond="...">
+	tag_else       // <else>
+	tag_if_end     // </if>
+	tag_cond_end   // "> of <if cond="...">
+
+	token_max
+)
+
+// token_texts are the texts of punctuations, keywords and tags, they are used to describe the tokens in error messages
+var token_texts = map[int]string{
+	token_comment:        "//",
+	token_define:         ":=",
+	token_equal:          "==",
+	token_not_equal:      "!=",
+	token_great_equal:    ">=",
+	token_less_equal:     "<=",
+	token_great:          ">",
+	token_less:           "<",
+	token_assign:         "=",
+	token_comma:          ",",
+	token_colon:          ":",
+	token_brace_left:     "{",
+	token_brace_right:    "}",
+	token_brackets_left:  "(",
+	token_brackets_right: ")",
+	token_square_left:    "[",
+	token_square_right:   "]",
+	token_range:          "..",
+	token_keyword_if:     "if",
+	token_keyword_elif:   "elif",
+	token_keyword_else:   "else",
+	token_keyword_print:  "print",
+	token_keyword_and:    "&&",
+	token_keyword_or:     "||",
+	token_keyword_not:    "!",
+	token_keyword_in:     "in",
+	token_keyword_not_in: "not in",
+
+	tag_soscript_start: "<soscript>",
+	tag_soscript_end:   "</soscript>",
+
+	tag_default_start: "<default>",
+	tag_default_end:   "</default>",
+
+	tag_line_start: "<line>",
+	tag_line_end:   "</line>",
+
+	tag_code_start: "<code>",
+	tag_code_end:   "</code>",
+
+	tag_var_start: "<var>",
+	tag_var_end:   "</var>",
+
+	tag_origin_start: "<origin>",
+	tag_origin_end:   "</origin>",
+
+	tag_if_start:   "<if cond=",
+	tag_elif_start: "<elif cond=",
+	tag_else:       "<else>",
+	tag_if_end:     "</if>",
+}
+
+var token_names = map[int]string{
+	token_number:   "number",
+	token_string:   "string",
+	token_symbol:   "identifier",
+	token_code:     "code",
+	token_origin:   "origin code",
+	token_bare:     "value",
+	tag_if_start:   "'<if cond='",
+	tag_elif_start: "'<elif cond='",
+	tag_cond_end:   "end of cond",
+}
+
+// tokenTypeDesc describes the token type in error messages
+func tokenTypeDesc(tokenType int) string {
+	if tokenType == -1 {
+		return "EOF"
+	}
+	if name, ok := token_names[tokenType]; ok {
+		return name
+	}
+	if text, ok := token_texts[tokenType]; ok {
+		return "'" + text + "'"
+	}
+	return "token"
+}
+
+// tokenDesc describes the token in error messages
+func tokenDesc(token *token) string {
+	switch token.tokenType {
+	case -1:
+		return "EOF"
+	case token_number, token_string, token_symbol, token_bare:
+		return tokenTypeDesc(token.tokenType) + " " + token.text
+	}
+	return "'" + token.text + "'"
+}
+
+type token struct {
+	lineno    int
+	column    int
+	tokenType int
+	text      string
+	lexer     *lexer
+}
+
+type lexer struct {
+	fileType     string
+	fileName     string
+	lines        []string
+	lineBreaks   []string // the line break of each line, \n or \r\n, empty for the last line without one
+	tokens       []*token
+	currTokenIdx int
+	diagnostics  []*Diagnostic
+
+	in_soscript bool
+	in_default  bool
+	in_code     bool
+	in_if_block bool
+	// multi-line <code> being lexed
+	codeLines  []string
+	codeLineno int
+	codeColumn int
+	comment    *commentStyle
+
+	// streaming mode, lines are read and lexed when the parser asks for tokens, only the lines of the current block are kept
+	stream    *bufio.Reader
+	out       io.Writer // the lines out of blocks are copied to out as soon as they are read
+	outErr    error
+	eof       bool
+	lineCount int // number of lines read
+	lineBase  int // number of lines released before lines[0]
+	//in_line bool
+	//in_code bool
+	//in_var bool
+}
+
+func newLexer(fileType string, fileName string, reader io.Reader) *lexer {
+	lexer := &lexer{
+		fileName:    fileName,
+		in_soscript: false,
+		in_default:  false,
+		comment:     commentStyleOf(fileName),
+		//in_line: false,
+		//in_code: false,
+		//in_var: false,
+	}
+	lexer.init(fileType, reader)
+	return lexer
+}
+
+// newSourceLexer lexes the source file with the comment style, the style is detected from the file name when it is nil
+func newSourceLexer(fileName string, comment *commentStyle, reader io.Reader) *lexer {
+	if comment == nil {
+		comment = commentStyleOf(fileName)
+	}
+	lexer := &lexer{fileName: fileName, comment: comment}
+	lexer.init("not_ss", reader)
+	return lexer
+}
+
+// newStreamLexer lexes the source file on demand with bounded memory, the tokens are lexed when the parser asks for them,
+// and the lines out of soscript blocks and if blocks are copied to out. The lines of a block are kept until release
+func newStreamLexer(fileName string, comment *commentStyle, reader io.Reader, out io.Writer) *lexer {
+	if comment == nil {
+		comment = commentStyleOf(fileName)
+	}
+	return &lexer{fileType: "not_ss", fileName: fileName, comment: comment, stream: bufio.NewReader(reader), out: out}
+}
+
+func (lexer *lexer) init(fileType string, reader io.Reader) {
+	lexer.fileType = fileType
+	lexer.currTokenIdx = 0
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, lineBreak, ok := lexer.readLine(bufReader)
+		if !ok {
+			break
+		}
+		lexer.lines = append(lexer.lines, line)
+		lexer.lineBreaks = append(lexer.lineBreaks, lineBreak)
+	}
+	if lexer.fileType == "json" {
+		lexer.start_json()
+	}
+	for k, v := range lexer.lines {
+		lexer.parseLine(k+1, v)
+	}
+	lexer.checkEnd()
+
+	//for _, v := range lexer.tokens {
+	//	log.Println("line ", v.lineno, v.text)
+	//}
+}
+
+// readLine reads a line, and returns it without the line break and the line break, false is returned at the end of file.
+// Every line keeps its own line break, so the lines that are not compiled are written as they are
+func (lexer *lexer) readLine(reader *bufio.Reader) (string, string, bool) {
+	line, err := reader.ReadString('\n')
+	if len(line) == 0 && err != nil {
+		return "", "", false
+	}
+	lineBreak := ""
+	if strings.HasSuffix(line, "\r\n") {
+		lineBreak = "\r\n"
+	} else if strings.HasSuffix(line, "\n") {
+		lineBreak = "\n"
+	}
+	lexer.lineCount++
+	return line[:len(line)-len(lineBreak)], lineBreak, true
+}
+
+// checkEnd reports the tags left open at the end of file
+func (lexer *lexer) checkEnd() {
+	if lexer.in_code {
+		lexer.error(lexer.codeLineno, lexer.codeColumn, "missing </code>")
+	}
+}
+
+func (lexer *lexer) parseLine(lineno int, lineText string) {
+	line := lineText
+	switch lexer.fileType {
+	case "ss":
+		lexer.start_ss(lineno, line)
+	case "not_ss":
+		lexer.start_not_ss(lineno, line)
+	case "yaml":
+		lexer.start_yaml(lineno, line)
+	case "toml":
+		lexer.start_toml(lineno, line)
+	case "env":
+		lexer.start_env(lineno, line)
+	}
+}
+
+func (lexer *lexer) addToken(lineno int, column int, tokenType int, text string) {
+	lexer.tokens = append(lexer.tokens, &token{lineno: lineno, column: column, tokenType: tokenType, text: text, lexer: lexer})
+}
+
+// addTrimToken adds the token of text with the space around it trimmed,
+// offset is the byte offset of text in the whole line
+func (lexer *lexer) addTrimToken(lineno int, offset int, tokenType int, text string) {
+	column := offset + len(text) - len(strings.TrimLeft(text, " \t")) + 1
+	lexer.addToken(lineno, column, tokenType, strings.TrimSpace(text))
+}
+
+func (lexer *lexer) error(lineno int, column int, m string) {
+	lexer.diagnostics = append(lexer.diagnostics, newDiagnostic(SEVERITY_ERROR, lexer.fileName, lineno, column, m, lexer.line(lineno)))
+}
+
+func (lexer *lexer) start_ss(lineno int, line string) {
+	scanner := newScanner(lexer, lineno, 0, line)
+	for token := scanner.scan(); token != nil; token = scanner.scan() {
+		// do not process comment words
+		if token.tokenType == token_comment {
+			return
+		}
+		lexer.tokens = append(lexer.tokens, token)
+	}
+}
+
+func (lexer *lexer) start_not_ss(lineno int, line string) {
+	// check: <soscript>
+	if lexer.in_soscript == false {
+		if start, _ := lexer.comment.findTag(line, tag_soscript_start); start >= 0 {
+			lexer.in_soscript = true
+			lexer.addToken(lineno, start+1, tag_soscript_start, "<soscript>")
+			return
+		}
+		lexer.do_block_tag(lineno, line)
+	} else {
+		lexer.do_in_soscript(lineno, line)
+	}
+}
+
+// do_block_tag checks the tags of block form after the comment mark: <if cond="...">, <elif cond="...">, <else> and </if>
+func (lexer *lexer) do_block_tag(lineno int, line string) {
+	if start, end := lexer.comment.findTag(line, tag_if_start); start >= 0 {
+		lexer.in_if_block = true
+		lexer.addToken(lineno, start+1, tag_if_start, "<if")
+		lexer.do_in_block_cond(lineno, line, end)
+		return
+	}
+	if start, end := lexer.comment.findTag(line, tag_elif_start); start >= 0 {
+		lexer.addToken(lineno, start+1, tag_elif_start, "<elif")
+		lexer.do_in_block_cond(lineno, line, end)
+		return
+	}
+	if start, _ := lexer.comment.findTag(line, tag_else); start >= 0 {
+		lexer.addToken(lineno, start+1, tag_else, "<else>")
+		return
+	}
+	if start, _ := lexer.comment.findTag(line, tag_if_end); start >= 0 {
+		lexer.in_if_block = false
+		lexer.addToken(lineno, start+1, tag_if_end, "</if>")
+		return
+	}
+}
+
+// do_in_block_cond lexes the quoted condition starts at offset, the condition is closed by the last quote before >,
+// so both cond='platform == "h5"' and cond="platform == "h5"" work
+func (lexer *lexer) do_in_block_cond(lineno int, line string, offset int) {
+	if offset >= len(line) || (line[offset] != '"' && line[offset] != '\'') {
+		lexer.error(lineno, offset+1, "cond should be quoted")
+		return
+	}
+	quote := line[offset : offset+1]
+	end := strings.LastIndex(line, quote+">")
+	if end <= offset {
+		lexer.error(lineno, offset+1, "missing "+quote+">")
+		return
+	}
+	lexer.lex_tokens(lineno, offset+1, line[offset+1:end])
+	lexer.addToken(lineno, end+1, tag_cond_end, quote+">")
+}
+
+func (lexer *lexer) do_in_soscript(lineno int, line string) {
+	if lexer.in_code {
+		lexer.do_in_code_line(lineno, line)
+		return
+	}
+	// check: <default>
+	if lexer.in_default == false {
+		if start, _ := lexer.comment.findTag(line, tag_default_start); start >= 0 {
+			lexer.in_default = true
+			lexer.addToken(lineno, start+1, tag_default_start, "<default>")
+			return
+		}
+	} else {
+		lexer.do_in_default(lineno, line)
+		return
+	}
+
+	// check: <line>
+	if start, _ := lexer.comment.findTag(line, tag_line_start); start >= 0 {
+		line := lexer.comment.trimEnd(line[start:])
+		//lexer.tokens = append(lexer.tokens, &token{lineno: lineno, tokenType: tag_line_start, text: "<line>"})
+		lexer.do_in_line(lineno, start, line)
+		return
+	}
+
+	// check: </soscript>
+	if start, _ := lexer.comment.findTag(line, tag_soscript_end); start >= 0 {
+		lexer.in_soscript = false
+		lexer.addToken(lineno, start+1, tag_soscript_end, "</soscript>")
+		return
+	}
+}
+
+func (lexer *lexer) do_in_default(lineno int, line string) {
+	// check: </default>
+	if start, _ := lexer.comment.findTag(line, tag_default_end); start >= 0 {
+		lexer.in_default = false
+		lexer.addToken(lineno, start+1, tag_default_end, "</default>")
+		return
+	}
+	// check: <origin>, the default code saved by in-place compile
+	_, originStart := lexer.comment.findTag(line, tag_origin_start)
+	originEnd := strings.LastIndex(line, token_texts[tag_origin_end])
+	if originStart >= 0 && originEnd >= originStart {
+		lexer.addToken(lineno, originStart+1, token_origin, line[originStart:originEnd])
+	}
+}
+
+// do_in_line lexes the <line> directive, offset is the byte offset of the directive in the whole line
+func (lexer *lexer) do_in_line(lineno int, offset int, line string) {
+	//fmt.Println(lineno, line)
+	lexer.lex_tokens(lineno, offset, line)
+}
+
+// lex_tokens lexes the text into tokens, offset is the byte offset of the text in the whole line
+func (lexer *lexer) lex_tokens(lineno int, offset int, line string) {
+	scanner := newScanner(lexer, lineno, offset, line)
+	for token := scanner.scan(); token != nil; token = scanner.scan() {
+		lexer.tokens = append(lexer.tokens, token)
+		if token.tokenType != tag_code_start {
+			continue
+		}
+		// the code is not lexed, it is taken until </code>
+		code := line[scanner.pos:]
+		codeColumn := scanner.column(scanner.pos)
+		end := strings.Index(code, "</code>")
+		if end < 0 {
+			// the code goes on in the following lines
+			lexer.in_code = true
+			lexer.codeLines = nil
+			lexer.codeLineno = lineno
+			lexer.codeColumn = codeColumn
+			if strings.TrimSpace(code) != "" {
+				lexer.codeLines = append(lexer.codeLines, strings.TrimSpace(code))
+			}
+			return
+		}
+		scanner.pos += end + len("</code>")
+		lexer.do_in_code(lineno, codeColumn, codeColumn+end, code[:end])
+	}
+}
+
+// do_in_code_line lexes a line of multi-line <code>, the comment marks of the line are removed
+func (lexer *lexer) do_in_code_line(lineno int, line string) {
+	start, end := findTag(line, tag_code_end)
+	if start < 0 {
+		lexer.codeLines = append(lexer.codeLines, lexer.comment.uncomment(line))
+		return
+	}
+	if codeLine := lexer.comment.uncomment(line[:start]); codeLine != "" {
+		lexer.codeLines = append(lexer.codeLines, codeLine)
+	}
+	lexer.in_code = false
+	lexer.addToken(lexer.codeLineno, lexer.codeColumn, token_code, strings.Join(lexer.codeLines, "\n"))
+	lexer.addToken(lineno, start+1, tag_code_end, "</code>")
+	lexer.lex_tokens(lineno, end, lexer.comment.trimEnd(line[end:]))
+}
+
+func (lexer *lexer) do_in_code(lineno int, column int, endColumn int, code string) {
+	lexer.addTrimToken(lineno, column-1, token_code, code)
+	lexer.addToken(lineno, endColumn, tag_code_end, "</code>")
+}
+
+func (lexer *lexer) takeToken() *token {
+	lexer.fill(0)
+	if lexer.currTokenIdx >= len(lexer.tokens) {
+		return nil
+	}
+	ret := lexer.tokens[lexer.currTokenIdx]
+	lexer.currTokenIdx++
+	//fmt.Println("takeToken: ", ret.text)
+	return ret
+}
+
+func (lexer *lexer) nextTokenType() int {
+	lexer.fill(0)
+	if lexer.currTokenIdx >= len(lexer.tokens) {
+		return -1
+	}
+	ret := lexer.tokens[lexer.currTokenIdx]
+	//fmt.Println("nextTokenType: ", ret.text)
+	return ret.tokenType
+}
+
+// peekTokenType returns the type of the token n tokens after the next one
+func (lexer *lexer) peekTokenType(n int) int {
+	lexer.fill(n)
+	if lexer.currTokenIdx+n >= len(lexer.tokens) {
+		return -1
+	}
+	return lexer.tokens[lexer.currTokenIdx+n].tokenType
+}
+
+func (lexer *lexer) currToken() *token {
+	lexer.fill(0)
+	if lexer.currTokenIdx >= len(lexer.tokens) {
+		return nil
+	}
+	ret := lexer.tokens[lexer.currTokenIdx]
+	return ret
+}
+
+// reset rewinds the tokens, so that the lexed file can be parsed again
+func (lexer *lexer) reset() {
+	lexer.currTokenIdx = 0
+}
+
+// eofToken is used to report errors at the end of file
+func (lexer *lexer) eofToken() *token {
+	lineno := lexer.lineCount
+	column := len(lexer.line(lineno)) + 1
+	return &token{lineno: lineno, column: column, tokenType: -1, text: "EOF", lexer: lexer}
+}
+
+// line returns the text of line lineno, empty for the line released by streaming lexer
+func (lexer *lexer) line(lineno int) string {
+	i := lineno - 1 - lexer.lineBase
+	if i < 0 || i >= len(lexer.lines) {
+		return ""
+	}
+	return lexer.lines[i]
+}
+
+// sliceLines returns the lines from index i to j, the same as lexer.lines[i:j] when no line is released
+func (lexer *lexer) sliceLines(i int, j int) []string {
+	return lexer.lines[i-lexer.lineBase : j-lexer.lineBase]
+}
+
+// lineBreak returns the line break of line lineno, empty for the last line without one and the line released by streaming lexer
+func (lexer *lexer) lineBreak(lineno int) string {
+	i := lineno - 1 - lexer.lineBase
+	if i < 0 || i >= len(lexer.lineBreaks) {
+		return ""
+	}
+	return lexer.lineBreaks[i]
+}
+
+// blockLineBreak returns the line break of the k-th of the n lines generated for the block from startLineno to endLineno.
+// The generated lines take the line breaks of the source lines of the block in order, and the last one takes the line break of the end line,
+// so the block of the same lines is written as it is
+func (lexer *lexer) blockLineBreak(startLineno int, endLineno int, k int, n int) string {
+	if k == n-1 {
+		return lexer.lineBreak(endLineno)
+	}
+	lineno := startLineno + k
+	if lineno >= endLineno {
+		lineno = endLineno - 1
+	}
+	if lineBreak := lexer.lineBreak(lineno); lineBreak != "" {
+		return lineBreak
+	}
+	return "\n"
+}
+
+// fill reads and lexes the lines of streaming lexer until there are n tokens after the next one or the file ends.
+// The line out of blocks is written to out and not kept
+func (lexer *lexer) fill(n int) {
+	for lexer.stream != nil && !lexer.eof && lexer.currTokenIdx+n >= len(lexer.tokens) {
+		line, lineBreak, ok := lexer.readLine(lexer.stream)
+		if !ok {
+			lexer.eof = true
+			lexer.checkEnd()
+			return
+		}
+		tokenCount := len(lexer.tokens)
+		lexer.lines = append(lexer.lines, line)
+		lexer.lineBreaks = append(lexer.lineBreaks, lineBreak)
+		lexer.parseLine(lexer.lineCount, line)
+		if len(lexer.tokens) > tokenCount || lexer.in_soscript || lexer.in_if_block {
+			continue
+		}
+		lexer.writeLine(line, lineBreak)
+		if len(lexer.lines) == 1 {
+			lexer.lines = nil
+			lexer.lineBreaks = nil
+			lexer.lineBase = lexer.lineCount
+		}
+	}
+}
+
+// writeLine writes a line of output with its line break for streaming lexer
+func (lexer *lexer) writeLine(line string, lineBreak string) {
+	if lexer.outErr != nil {
+		return
+	}
+	_, lexer.outErr = io.WriteString(lexer.out, line+lineBreak)
+}
+
+// writeEnd returns the first error of writing the output
+func (lexer *lexer) writeEnd() error {
+	return lexer.outErr
+}
+
+// release drops the taken tokens of streaming lexer and the lines before the next token, after the block is written
+func (lexer *lexer) release() {
+	lexer.tokens = append([]*token(nil), lexer.tokens[lexer.currTokenIdx:]...)
+	lexer.currTokenIdx = 0
+	drop := len(lexer.lines)
+	if len(lexer.tokens) > 0 && lexer.tokens[0].lineno-1-lexer.lineBase < drop {
+		drop = lexer.tokens[0].lineno - 1 - lexer.lineBase
+	}
+	if drop > 0 {
+		lexer.lines = append([]string(nil), lexer.lines[drop:]...)
+		lexer.lineBreaks = append([]string(nil), lexer.lineBreaks[drop:]...)
+		lexer.lineBase += drop
+	}
+}
+
+// takeDiagnostics returns the diagnostics found since the last call, the streaming lexer finds them as it reads on
+func (lexer *lexer) takeDiagnostics() []*Diagnostic {
+	ret := lexer.diagnostics
+	lexer.diagnostics = nil
+	return ret
+}
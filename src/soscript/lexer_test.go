@@ -0,0 +1,38 @@
+package soscript
+
+import (
+	"strings"
+	"testing"
+)
+
+// lexTags lexes the source and returns the texts of its tags
+func lexTags(fileName string, source string) []string {
+	lexer := newSourceLexer(fileName, nil, strings.NewReader(source))
+	var tags []string
+	for _, v := range lexer.tokens {
+		if v.tokenType >= tag_soscript_start {
+			tags = append(tags, v.text)
+		}
+	}
+	return tags
+}
+
+// the tags are taken only right after the comment mark at the start of a line
+func TestTagsAfterCommentMark(t *testing.T) {
+	tests := []struct {
+		fileName string
+		source   string
+		tags     string
+	}{
+		{"a.java", "s = \"<soscript>\";\nt = \"<default>\"; // </soscript>\n", ""},
+		{"a.java", "  //<soscript>\n  // <default>\n  x();\n  // </default>\n  //   </soscript>\n", "<soscript> <default> </default> </soscript>"},
+		{"a.py", "# <soscript>\n// <default>\n# <default>\n# </default>\n# </soscript>\n", "<soscript> <default> </default> </soscript>"},
+		{"a.html", "<!-- <soscript> -->\n<p>// <soscript></p>\n<!-- </soscript> -->\n", "<soscript> </soscript>"},
+		{"a.java", "x = \"<if cond='a'>\";\n// <if cond=\"a\">\n// <else>\ny = \"</if>\";\n// </if>\n", "<if \"> <else> </if>"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(lexTags(test.fileName, test.source), " "); got != test.tags {
+			t.Errorf("%q: got tags %q, want %q", test.source, got, test.tags)
+		}
+	}
+}
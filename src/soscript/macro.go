@@ -0,0 +1,205 @@
+package soscript
+
+import (
+	"fmt"
+	"strings"
+)
+
+// macro is a named condition declared in def file, eg. isMiniGame := platform == "wechat" || platform == "h5".
+// A macro with params is called like a function, eg. atLeast(v) := version >= v, and a macro without params is used like a variable.
+type macro struct {
+	token  *token
+	name   string
+	prefix string // namespace of the def file that declares the macro
+	params []string
+	body   exprNode
+	// the macro has errors in its body or is in a cycle, it is not expanded
+	invalid bool
+}
+
+// parse_macro parses the macro declaration, the body is parsed with the condition grammar of source files
+func (p *parser) parse_macro(token *token) {
+	prefix := p.defPrefixList[len(p.defPrefixList)-1]
+	m := &macro{token: token, name: prefix + token.text, prefix: prefix}
+	if _, ok := p.varDeclareSet[m.name]; ok {
+		p.parseError(token, fmt.Sprintf("%s has been declared as variable", m.name))
+	}
+	if _, ok := p.macroSet[m.name]; ok {
+		p.parseError(token, fmt.Sprintf("macro %s has been declared", m.name))
+	}
+	if p.defLexer.nextTokenType() == token_brackets_left {
+		p.checkDefToken(token_brackets_left)
+		for p.defLexer.nextTokenType() != token_brackets_right {
+			if len(m.params) > 0 {
+				p.checkDefToken(token_comma)
+			}
+			param := p.checkDefToken(token_symbol)
+			if m.isParam(param.text) {
+				p.parseError(param, fmt.Sprintf("param %s of %s is given twice", param.text, m.name))
+			}
+			m.params = append(m.params, param.text)
+		}
+		p.checkDefToken(token_brackets_right)
+	}
+	p.checkDefToken(token_define)
+	sourceLexer := p.sourceLexer
+	p.sourceLexer = p.defLexer
+	defer func() {
+		p.sourceLexer = sourceLexer
+	}()
+	m.body = p.parse_logic_expr(1)
+	p.macroSet[m.name] = m
+	p.macroNameList = append(p.macroNameList, m.name)
+}
+
+func (m *macro) isParam(name string) bool {
+	for _, v := range m.params {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String is the declaration of macro, eg. atLeast(v) := version >= v
+func (m *macro) String() string {
+	if len(m.params) == 0 {
+		return fmt.Sprintf("%s := %s", m.name, m.body.String())
+	}
+	return fmt.Sprintf("%s(%s) := %s", m.name, strings.Join(m.params, ", "), m.body.String())
+}
+
+// checkMacros resolves the names used by the macros after all def files are parsed, so that a macro can use the ones declared after it.
+// A name is looked up in the namespace of the macro, then in the outer namespaces.
+func (p *parser) checkMacros() {
+	for _, name := range p.macroNameList {
+		m := p.macroSet[name]
+		walkExpr(m.body, func(expr exprNode) {
+			switch v := expr.(type) {
+			case *identExpr:
+				if m.isParam(v.name) {
+					return
+				}
+				if qualified, ok := p.resolveName(m.prefix, v.name); ok {
+					v.name = qualified
+					return
+				}
+				p.addError(v.token, fmt.Sprintf("%s is not declared, used by macro %s", v.name, m.name))
+				m.invalid = true
+			case *callExpr:
+				if qualified, ok := p.resolveName(m.prefix, v.name); ok && p.macroSet[qualified] != nil {
+					v.name = qualified
+					return
+				}
+				p.addError(v.token, fmt.Sprintf("macro %s is not declared, used by macro %s", v.name, m.name))
+				m.invalid = true
+			}
+		})
+	}
+	p.checkMacroCycles()
+	// the calls in the bodies are expanded, so they are checked after the cycles are found
+	for _, name := range p.macroNameList {
+		p.checkLiterals(p.macroSet[name].body)
+	}
+}
+
+func (p *parser) resolveName(prefix string, name string) (string, bool) {
+	for {
+		if _, ok := p.varDeclareSet[prefix+name]; ok {
+			return prefix + name, true
+		}
+		if _, ok := p.macroSet[prefix+name]; ok {
+			return prefix + name, true
+		}
+		if prefix == "" {
+			return "", false
+		}
+		// common.channel. -> common.
+		prefix = prefix[:strings.LastIndex(strings.TrimSuffix(prefix, "."), ".")+1]
+	}
+}
+
+// macroRefs are the macros used by the body of macro
+func (p *parser) macroRefs(m *macro) []*macro {
+	var ret []*macro
+	walkExpr(m.body, func(expr exprNode) {
+		name := ""
+		switch v := expr.(type) {
+		case *identExpr:
+			name = v.name
+		case *callExpr:
+			name = v.name
+		}
+		if ref, ok := p.macroSet[name]; ok && !m.isParam(name) {
+			ret = append(ret, ref)
+		}
+	})
+	return ret
+}
+
+// checkMacroCycles reports the macros that expand to themselves, they are marked invalid so that the expansion ends
+func (p *parser) checkMacroCycles() {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, 0)
+	var path []*macro
+	var visit func(m *macro)
+	visit = func(m *macro) {
+		switch state[m.name] {
+		case visited:
+			return
+		case visiting:
+			var names []string
+			for i := len(path) - 1; i >= 0; i-- {
+				names = append([]string{path[i].name}, names...)
+				path[i].invalid = true
+				if path[i] == m {
+					break
+				}
+			}
+			p.addError(m.token, "macro cycle: "+strings.Join(append(names, m.name), " -> "))
+			return
+		}
+		state[m.name] = visiting
+		path = append(path, m)
+		for _, ref := range p.macroRefs(m) {
+			visit(ref)
+		}
+		path = path[:len(path)-1]
+		state[m.name] = visited
+	}
+	for _, name := range p.macroNameList {
+		visit(p.macroSet[name])
+	}
+}
+
+// expand replaces the params in body with the args, args with operators are put in brackets
+func (m *macro) expand(args []exprNode) exprNode {
+	argSet := make(map[string]exprNode, len(args))
+	for i, v := range args {
+		switch v.(type) {
+		case *binaryExpr, *inExpr:
+			v = &parenExpr{token: v.exprToken(), inner: v}
+		}
+		argSet[m.params[i]] = v
+	}
+	return substituteExpr(m.body, argSet)
+}
+
+// evalMacro evaluates the expansion of macro, the errors in the expansion are reported at expr with the expansion
+func evalMacro(expr exprNode, m *macro, args []exprNode, env evalEnv) (value, *evalError) {
+	if m.invalid {
+		return value{}, newEvalError(expr, "macro %s has errors in its declaration", m.name)
+	}
+	if len(args) != len(m.params) {
+		return value{}, newEvalError(expr, "macro %s takes %d argument(s), but %d are given", m.name, len(m.params), len(args))
+	}
+	expansion := m.expand(args)
+	val, err := evalExpr(expansion, env)
+	if err != nil {
+		return value{}, newEvalError(expr, "%s, in %s expanded to %s", err.message, expr.String(), expansion.String())
+	}
+	return val, nil
+}
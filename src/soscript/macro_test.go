@@ -0,0 +1,84 @@
+package soscript
+
+import (
+	"testing"
+)
+
+const macroDefs = testDefs + `
+// the macros use each other in any order
+isMobileHigh := isMobile && atLeast(4)
+isMobile := platform == "android" || platform == "ios"
+atLeast(n) := level >= n
+between(lo, hi) := level >= lo && level <= hi
+negate(cond) := !cond
+`
+
+func macroSource(cond string) string {
+	return `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(` + cond + `) print(<code> int a = 1; </code>) </line>
+// </soscript>
+`
+}
+
+// the macros are expanded with the arguments and evaluated with the config
+func TestMacroExpansion(t *testing.T) {
+	tests := []struct {
+		config string
+		cond   string
+		output string
+	}{
+		{`platform = "ios"`, "isMobile", "int a = 1;\n"},
+		{`platform = "pc"`, "isMobile", "int a = 0;\n"},
+		{`platform = "ios"`, "isMobile && atLeast(3)", "int a = 1;\n"},
+		{`platform = "ios"`, "isMobileHigh", "int a = 0;\n"},
+		{"platform = \"android\"\nlevel = 4", "isMobileHigh", "int a = 1;\n"},
+		{"platform = \"pc\"\nlevel = 2", "between(1, 2) && !between(3, 5)", "int a = 1;\n"},
+		// the argument is expanded as a whole, not as text
+		{`platform = "ios"`, `negate(platform == "pc" || platform == "ios")`, "int a = 0;\n"},
+	}
+	for _, test := range tests {
+		output, err := compileTest(t, macroDefs, test.config, macroSource(test.cond))
+		if err != nil {
+			t.Errorf("%s: %v", test.cond, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%s with %q: got %q, want %q", test.cond, test.config, output, test.output)
+		}
+	}
+}
+
+func TestMacroArity(t *testing.T) {
+	tests := []struct {
+		cond        string
+		diagnostics []string
+	}{
+		{"atLeast()", []string{"src.java:5: macro atLeast takes 1 argument(s), but 0 are given"}},
+		{"between(1, 2, 3)", []string{"src.java:5: macro between takes 2 argument(s), but 3 are given"}},
+		// errors in the expansion are reported with it
+		{`atLeast("x")`, []string{`src.java:5: can not compare INT with STRING, in atLeast("x") expanded to level >= "x"`}},
+	}
+	for _, test := range tests {
+		_, err := compileTest(t, macroDefs, `platform = "pc"`, macroSource(test.cond))
+		checkDiagnostics(t, err, test.diagnostics)
+	}
+}
+
+func TestMacroDeclareErrors(t *testing.T) {
+	tests := []struct {
+		defs        string
+		diagnostics []string
+	}{
+		{"a := b\nb := a\n", []string{"def.ss:6: macro cycle: a -> b -> a"}},
+		{"a := c\n", []string{"def.ss:6: c is not declared, used by macro a"}},
+		{"f(x, x) := x\n", []string{"def.ss:6: param x of f is given twice"}},
+		{"platform := true\n", []string{"def.ss:6: platform has been declared as variable"}},
+	}
+	for _, test := range tests {
+		_, err := compileTest(t, testDefs+test.defs, `platform = "pc"`, macroSource("true"))
+		checkDiagnostics(t, err, test.diagnostics)
+	}
+}
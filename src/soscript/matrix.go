@@ -0,0 +1,294 @@
+package soscript
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matrixVar is a variable of build matrix, vals are the token texts of the declared values
+type matrixVar struct {
+	varDeclare *varDecl
+	vals       []string
+}
+
+// matrix_max_range is the most values of an int range that are enumerated by matrix
+const matrix_max_range = 16
+
+// matrixVars are the global variables with value list or small int range that are not assigned in config,
+// in the order of declaration. The variables with default are also part of the matrix when config does not assign them.
+func matrixVars(parser *parser) []*matrixVar {
+	var ret []*matrixVar
+	for _, name := range parser.varNameList {
+		varDeclare := parser.varDeclareSet[name]
+		vals := varDeclare.valList
+		if varDeclare.anyVal {
+			vals = rangeVals(varDeclare)
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		if varDeclare.currVal != "" && varDeclare.valToken != varDeclare.defaultToken {
+			continue
+		}
+		ret = append(ret, &matrixVar{varDeclare: varDeclare, vals: vals})
+	}
+	return ret
+}
+
+// rangeVals are the values of int variable with both bounds, nil is returned for other variables and the range of more than matrix_max_range values
+func rangeVals(varDeclare *varDecl) []string {
+	if varDeclare.varType != "INT" || varDeclare.rangeMin == nil || varDeclare.rangeMax == nil {
+		return nil
+	}
+	min, _ := strconv.Atoi(varDeclare.rangeMin.text)
+	max, _ := strconv.Atoi(varDeclare.rangeMax.text)
+	if max-min+1 > matrix_max_range {
+		return nil
+	}
+	var ret []string
+	for i := min; i <= max; i++ {
+		ret = append(ret, strconv.Itoa(i))
+	}
+	return ret
+}
+
+// forEachCombination assigns every combination of values to the matrix variables, the last variable changes fastest,
+// the values are restored at the end
+func forEachCombination(vars []*matrixVar, f func()) {
+	idx := make([]int, len(vars))
+	saved := make([]string, len(vars))
+	for i, v := range vars {
+		saved[i] = v.varDeclare.currVal
+	}
+	for {
+		for i, v := range vars {
+			v.varDeclare.currVal = v.vals[idx[i]]
+		}
+		f()
+		i := len(vars) - 1
+		for ; i >= 0; i-- {
+			idx[i]++
+			if idx[i] < len(vars[i].vals) {
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	for i, v := range vars {
+		v.varDeclare.currVal = saved[i]
+	}
+}
+
+var combination_name_rule = regexp.MustCompile(`[^\w.]+`)
+
+// combinationName is the output dir name of the current combination, eg. ios-1.0.1-release-no-enableLog-level=5.
+// A bool value is named as name or no-name and a number as name=value, so that the name tells whose value it is
+func combinationName(vars []*matrixVar) string {
+	names := make([]string, 0, len(vars))
+	for _, v := range vars {
+		val := literalValue(v.varDeclare.varType, v.varDeclare.currVal)
+		name := combination_name_rule.ReplaceAllString(val.text, "_")
+		varName := combination_name_rule.ReplaceAllString(v.varDeclare.name, "_")
+		switch v.varDeclare.varType {
+		case "BOOL":
+			name = varName
+			if !val.isTrue() {
+				name = "no-" + varName
+			}
+		case "INT", "FLOAT":
+			name = varName + "=" + name
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "default"
+	}
+	return strings.Join(names, "-")
+}
+
+// combinationDesc describes the current combination in messages, eg. platform="ios", mode="release"
+func combinationDesc(vars []*matrixVar) string {
+	descs := make([]string, 0, len(vars))
+	for _, v := range vars {
+		descs = append(descs, v.varDeclare.name+"="+v.varDeclare.currVal)
+	}
+	return strings.Join(descs, ", ")
+}
+
+// evalFilter tells whether the current combination is selected by the filter, the filter is nil when there is no filter
+func evalFilter(parser *parser, filter exprNode) (bool, *evalError) {
+	if filter == nil {
+		return true, nil
+	}
+	val, err := evalExpr(filter, &soscriptEnv{parser: parser})
+	if err != nil {
+		return false, err
+	}
+	if val.valType != "BOOL" {
+		return false, newEvalError(filter, "filter is %s, but BOOL is expected", val.valType)
+	}
+	return val.isTrue(), nil
+}
+
+// parseFilter parses the condition of --filter, nil is returned when there is no filter.
+// The diagnostics of an invalid filter are returned and removed from parser
+func parseFilter(parser *parser, filterText string) (exprNode, error) {
+	if filterText == "" {
+		return nil, nil
+	}
+	loadedDiagnostics := len(parser.diagnostics)
+	filter := parser.parseCondition(newLexer("ss", "--filter", strings.NewReader(filterText)))
+	if filter == nil {
+		err := newError(parser.diagnostics[loadedDiagnostics:], nil)
+		parser.diagnostics = parser.diagnostics[:loadedDiagnostics]
+		return nil, err
+	}
+	return filter, nil
+}
+
+// combinationReporter reports the diagnostics of each combination, so that they do not fail other combinations.
+// A diagnostic found by more than one combination is reported once. With groupFailures the failed combinations
+// are kept by their diagnostics, and written by writeFailures
+type combinationReporter struct {
+	parser          *parser
+	w               io.Writer
+	baseDiagnostics int
+	reported        map[string]bool
+	groupFailures   bool
+	failures        []*failureGroup
+	failureSet      map[string]*failureGroup
+}
+
+// failureGroup is the combinations that fail with the same diagnostics
+type failureGroup struct {
+	diagnostics  []*Diagnostic
+	combinations []string
+}
+
+// newCombinationReporter reports to w, the diagnostics are discarded when w is nil
+func newCombinationReporter(parser *parser, w io.Writer) *combinationReporter {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	return &combinationReporter{parser: parser, w: w, baseDiagnostics: len(parser.diagnostics), reported: map[string]bool{}, failureSet: map[string]*failureGroup{}}
+}
+
+// flush reports the diagnostics of the current combination and removes them from parser, returns whether there is any error
+func (r *combinationReporter) flush(vars []*matrixVar) bool {
+	diagnostics := r.parser.diagnostics[r.baseDiagnostics:]
+	r.parser.diagnostics = r.parser.diagnostics[:r.baseDiagnostics]
+	var newDiagnostics []*Diagnostic
+	for _, v := range diagnostics {
+		if !r.reported[v.String()] {
+			r.reported[v.String()] = true
+			newDiagnostics = append(newDiagnostics, v)
+		}
+	}
+	hasError := hasDiagnosticError(diagnostics)
+	if hasError && r.groupFailures {
+		// copy the diagnostics, the parser reuses the array for the next combination
+		diagnostics = append([]*Diagnostic(nil), diagnostics...)
+		sortDiagnostics(diagnostics)
+		key := formatDiagnostics(diagnostics)
+		group := r.failureSet[key]
+		if group == nil {
+			group = &failureGroup{diagnostics: diagnostics}
+			r.failureSet[key] = group
+			r.failures = append(r.failures, group)
+		}
+		group.combinations = append(group.combinations, combinationDesc(vars))
+		return true
+	}
+	if hasError {
+		fmt.Fprintf(r.w, "failed: %s\n", combinationDesc(vars))
+	}
+	if len(newDiagnostics) > 0 {
+		sortDiagnostics(newDiagnostics)
+		fmt.Fprintln(r.w, formatDiagnostics(newDiagnostics))
+	}
+	return hasError
+}
+
+// writeFailures writes every group of failed combinations once, with the diagnostics they fail with
+func (r *combinationReporter) writeFailures() {
+	for _, v := range r.failures {
+		fmt.Fprintf(r.w, "failed: %s\n", strings.Join(v.combinations, "; "))
+		fmt.Fprintln(r.w, formatDiagnostics(v.diagnostics))
+	}
+}
+
+// MatrixSummary counts the combinations of build matrix
+type MatrixSummary struct {
+	Combinations int
+	Built        int
+	Failed       int
+	Filtered     int
+}
+
+func (s *MatrixSummary) String() string {
+	return fmt.Sprintf("%d combinations: %d built, %d failed, %d filtered out", s.Combinations, s.Built, s.Failed, s.Filtered)
+}
+
+// buildMatrix compiles the source files once for every combination of variable values into outputDir/<combination>,
+// the sources are lexed once and shared by all combinations. The built combinations are printed to log,
+// the failed ones and their diagnostics to errLog. The error of writing the output stops the matrix
+func buildMatrix(parser *parser, files []*sourceFile, outputDir string, filterText string, log io.Writer, errLog io.Writer) (*MatrixSummary, error) {
+	filter, err := parseFilter(parser, filterText)
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = ioutil.Discard
+	}
+	vars := matrixVars(parser)
+	summary := &MatrixSummary{}
+	reporter := newCombinationReporter(parser, errLog)
+	var writeErr error
+	forEachCombination(vars, func() {
+		if writeErr != nil {
+			return
+		}
+		summary.Combinations++
+		selected, err := evalFilter(parser, filter)
+		if err != nil {
+			parser.addError(err.token, err.message)
+			selected = false
+		}
+		if !selected {
+			if err == nil {
+				summary.Filtered++
+			} else {
+				summary.Failed++
+			}
+		} else {
+			name := combinationName(vars)
+			_, writeErr = compileSourceFiles(parser, files, filepath.Join(outputDir, name), false)
+			if writeErr != nil {
+				return
+			}
+			if parser.hasError() {
+				summary.Failed++
+			} else {
+				summary.Built++
+				fmt.Fprintln(log, "built:", name)
+			}
+		}
+		reporter.flush(vars)
+	})
+	if writeErr != nil {
+		parser.diagnostics = parser.diagnostics[:reporter.baseDiagnostics]
+		return summary, writeErr
+	}
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("matrix failed with %d of %d combination(s)", summary.Failed, summary.Combinations)
+	}
+	return summary, nil
+}
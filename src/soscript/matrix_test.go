@@ -0,0 +1,100 @@
+package soscript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const matrixDefs = `platform: {"android", "ios"}
+
+enableLog: bool
+
+level: int[1..2]
+	default 1
+`
+
+const matrixSource = `// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(platform == "ios" && enableLog) print(<code> int a = <var>level</var>; </code>) </line>
+// </soscript>
+`
+
+// buildTestMatrix builds the matrix of matrixSource and returns the combination dirs with the output of each
+func buildTestMatrix(t *testing.T, config string, filter string) (map[string]string, *MatrixSummary, error) {
+	t.Helper()
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "a.java"), []byte(matrixSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := LoadSources(sourceDir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	cfg := loadTestConfig(t, matrixDefs, config)
+	summary, err := BuildMatrix(cfg, sources, outputDir, &Options{Filter: filter})
+	built := map[string]string{}
+	for name, data := range listFiles(t, outputDir) {
+		if strings.HasSuffix(name, "/a.java") {
+			built[strings.TrimSuffix(name, "/a.java")] = data
+		}
+	}
+	return built, summary, err
+}
+
+func keysOf(m map[string]string) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// bool values are named as name or no-name, numbers as name=value
+func TestMatrixNames(t *testing.T) {
+	built, summary, err := buildTestMatrix(t, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"android-enableLog-level=1", "android-enableLog-level=2", "android-no-enableLog-level=1", "android-no-enableLog-level=2",
+		"ios-enableLog-level=1", "ios-enableLog-level=2", "ios-no-enableLog-level=1", "ios-no-enableLog-level=2",
+	}
+	if got := keysOf(built); !reflect.DeepEqual(got, want) {
+		t.Errorf("got combinations %q, want %q", got, want)
+	}
+	if got := built["ios-enableLog-level=2"]; got != "int a = 2;\n" {
+		t.Errorf("got output %q", got)
+	}
+	if got := built["ios-no-enableLog-level=2"]; got != "int a = 0;\n" {
+		t.Errorf("got output %q", got)
+	}
+	if summary.Combinations != 8 || summary.Built != 8 {
+		t.Errorf("got summary %v", summary)
+	}
+}
+
+// the filter selects a subset, the variables assigned in config are not part of the matrix
+func TestMatrixFilter(t *testing.T) {
+	built, summary, err := buildTestMatrix(t, "enableLog = true", `platform == "ios" || level > 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"android-level=2", "ios-level=1", "ios-level=2"}
+	if got := keysOf(built); !reflect.DeepEqual(got, want) {
+		t.Errorf("got combinations %q, want %q", got, want)
+	}
+	if summary.Combinations != 4 || summary.Built != 3 || summary.Filtered != 1 {
+		t.Errorf("got summary %v", summary)
+	}
+
+	_, _, err = buildTestMatrix(t, "", `platform == "pc"`)
+	checkDiagnostics(t, err, []string{`--filter:1: value "pc" is not declared for platform`})
+}
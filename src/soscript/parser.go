@@ -0,0 +1,1353 @@
+package soscript
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+BNF Design:
+<def_statement> ::= <import> | <variable_declare> | <macro_declare>
+
+<macro_declare> ::= <identifier> := <logic_calc_expr>      |
+					<identifier>(<macro_params>) := <logic_calc_expr>
+
+<macro_params> ::= <macro_params>, <identifier>      |
+					<identifier>      |
+					""
+
+<import> ::= import <string>      |
+			import <string> as <identifier>
+
+<variable_declare> ::= <identifier>: {<variable_val>} <variable_attr_list>      |
+						<identifier>: <type> {<variable_val>} <variable_attr_list>      |
+						<identifier>: <type> <variable_attr_list>      |
+						<identifier>: <type>[<range>] <variable_attr_list>
+
+<range> ::= <number>..<number>      |
+			<number>..      |
+			..<number>
+
+<variable_attr_list> ::= <variable_attr_list> <variable_attr>      |
+						""
+
+<variable_attr> ::= default <const_val>      |
+					required      |
+					optional      |
+					desc <string>
+
+<type> ::= string | number | int | float | bool | version
+
+<variable_val> ::= <variable_val>, <const_val>      |
+					<const_val>
+
+<const_val> ::= <number>      |
+				<string>      |
+				true      |
+				false
+
+<identifier> ::= "\w+"      |
+				<identifier>.<identifier>
+
+<variable_assign> ::= <identifier> = <const_val>
+
+<config_extends> ::= extends <string>
+
+<if_chain> ::= <if_expr> <elif_list> <else_expr>      |
+				<if_expr> <elif_list>
+
+<elif_list> ::= <elif_list> <elif_expr>      |
+				""
+
+<if_expr> ::= if(<logic_calc_expr>) <print_expr>
+
+<elif_expr> ::= elif(<logic_calc_expr>) <print_expr>
+
+<else_expr> ::= else <print_expr>
+
+<block_assign> ::= <identifier> = <logic_calc_expr>
+
+<logic_calc_expr> ::= <logic_calc_expr> || <logic_term>    |
+					<logic_term>
+
+<logic_term> ::= <logic_term> && <equal_expr>     |
+					<equal_expr>
+
+<equal_expr> ::= <equal_expr> == <compare_expr>     |
+				<equal_expr> != <compare_expr>     |
+				<equal_expr> in (<in_list>)     |
+				<equal_expr> not in (<in_list>)     |
+				<compare_expr>
+
+<in_list> ::= <in_list>, <logic_calc_expr>     |
+				<logic_calc_expr>
+
+<compare_expr> ::= <compare_expr> <compare_op> <unary_expr>     |
+					<unary_expr>
+
+<compare_op> ::= ">" | "<" | ">=" | "<="
+
+<unary_expr> ::= ! <unary_expr>      |
+				<primary_expr>
+
+<primary_expr> ::= <identifier>      |
+				<const_val>      |
+				(<logic_calc_expr>)      |
+				<identifier>(<call_args>)
+
+<call_args> ::= <call_args>, <logic_calc_expr>      |
+				<logic_calc_expr>      |
+				""
+
+<print_expr> ::= print(<code> <code_expr> </code>)
+
+<if_block> ::= <if_tag> <source_code> <elif_block_list> <else_block> </if>      |
+				<if_tag> <source_code> <elif_block_list> </if>
+
+<if_tag> ::= <if cond="<logic_calc_expr>">
+
+<elif_block_list> ::= <elif_block_list> <elif cond="<logic_calc_expr>"> <source_code>      |
+						""
+
+<else_block> ::= <else> <source_code>
+
+<code_expr> ::= <code_expr> <var_expr> <code_expr>      |
+				<string>
+
+<var_expr> ::= <var><identifier></var>      |
+				<var><identifier>|<var_mode></var>
+
+<var_mode> ::= raw | quoted | json
+
+<tag> ::= "<soscript>" | "</soscript>" | "<default>" | "</default>" | "<line>" | "</line>" | "<code>" | "</code>" | "<var>" | "</var>"
+*/
+
+type varDecl struct {
+	token   *token // name token of declaration, nil for block variables
+	name    string
+	varType string
+	valList []string
+	scope   string
+	currVal string
+	// value variable declared without value list, any value of its type can be assigned
+	anyVal bool
+	// where currVal is assigned, the value token of config or the line of block assign, nil when not assigned
+	valToken *token
+	// value token of default in def file, nil when there is no default
+	defaultToken *token
+	// bounds of int or float variable, nil when the bound is not given
+	rangeMin *token
+	rangeMax *token
+	// required variable should be assigned by config, variables with value list are required unless they have default or are optional
+	required bool
+	desc     string
+}
+
+// describe is the variable name with its description for messages, eg. platform (platform that the program run on)
+func (v *varDecl) describe() string {
+	if v.desc == "" {
+		return v.name
+	}
+	return fmt.Sprintf("%s (%s)", v.name, v.desc)
+}
+
+// source tells where the value is assigned, eg. wechat_conf.ss:3: version = "1.0.1"
+func (v *varDecl) source() string {
+	token := v.valToken
+	if token == nil || token.lexer == nil {
+		return "not assigned"
+	}
+	return fmt.Sprintf("%s:%d: %s", token.lexer.fileName, token.lineno, strings.TrimSpace(token.lexer.line(token.lineno)))
+}
+
+const (
+	line_type_if = iota
+	line_type_elif
+	line_type_else
+	line_type_assign
+)
+
+// soscriptLine is a statement in <line>, either a branch of if chain or an assign of block variable
+type soscriptLine struct {
+	token     *token
+	lineType  int
+	name      string   // variable name of assign
+	expr      exprNode // nil for else
+	code      string   // code to print, <var> is not replaced
+	codeToken *token   // nil when the code is empty
+	val       value    // value of expr with the current config
+	selected  bool     // whether the code of this line is selected with the current config
+}
+
+type soscriptBlock struct {
+	startLineno        int
+	endLineno          int
+	defaultStartLineno int
+	defaultEndLineno   int
+	lineList           []*soscriptLine
+	varDeclareSet      map[string]*varDecl
+	// default code saved by a previous in-place compile
+	originLines []string
+	// code of the first <line> whose condition is true, <var> is replaced
+	code    string
+	matched bool
+}
+
+// ifBranch is a branch of if block, its code is the source lines between its tag and the next tag
+type ifBranch struct {
+	token    *token   // <if, <elif or <else>
+	expr     exprNode // nil for <else>
+	val      value
+	selected bool
+}
+
+// ifBlock is the block form of condition, the code in it is real source code instead of comments
+type ifBlock struct {
+	startLineno int
+	endLineno   int
+	branchList  []*ifBranch
+	selected    *ifBranch // nil when no branch is true
+}
+
+type parser struct {
+	defLexer      *lexer
+	configLexer   *lexer
+	sourceLexer   *lexer
+	varDeclareSet map[string]*varDecl
+	varNameList   []string // global variables in the order of declaration
+	macroSet      map[string]*macro
+	macroNameList []string
+	// config files being parsed, the outer file extends the inner one
+	configFileList []string
+	// dirs to find the imported def files after the dir of the importing file
+	importDirs []string
+	// def files being parsed and their namespaces, the outer file imports the inner one
+	defFileList   []string
+	defPrefixList []string
+	// imported def files by absolute path and namespace, a file is imported once under a namespace
+	importedSet  map[string]bool
+	soscriptList []*soscriptBlock
+	ifBlockList  []*ifBlock
+	diagnostics  []*Diagnostic
+}
+
+// parseAbort is the panic value of parseError, the parser recovers from it at the next statement
+type parseAbort struct{}
+
+func newParser(defLexer *lexer, configLexer *lexer, importDirs []string) *parser {
+	p := &parser{
+		varDeclareSet: make(map[string]*varDecl, 0),
+		macroSet:      make(map[string]*macro, 0),
+		defLexer:      defLexer,
+		configLexer:   configLexer,
+		importDirs:    importDirs,
+		importedSet:   make(map[string]bool, 0),
+		defFileList:   []string{defLexer.fileName},
+		defPrefixList: []string{""},
+	}
+	p.init()
+	return p
+}
+
+func (p *parser) init() {
+	p.diagnostics = append(p.diagnostics, p.defLexer.diagnostics...)
+	p.parseDef()
+	p.checkMacros()
+	p.parseConfigLayer(p.configLexer)
+}
+
+func (p *parser) parseDef() {
+	for p.defLexer.nextTokenType() != -1 {
+		p.parse_def_statement()
+	}
+}
+
+func (p *parser) parse_def_statement() {
+	defer p.recoverStatement(p.defLexer, func() bool {
+		return p.isDefStatementStart()
+	})
+	token := p.defLexer.takeToken()
+	switch token.tokenType {
+	case token_symbol:
+		if token.text == "import" && p.defLexer.nextTokenType() == token_string {
+			p.parse_import(token)
+			return
+		}
+		if p.defLexer.nextTokenType() == token_define || p.defLexer.nextTokenType() == token_brackets_left {
+			p.parse_macro(token)
+			return
+		}
+		p.parse_var_declare(token)
+	default:
+		p.parseError(token, "syntax error: unexpected "+tokenDesc(token))
+	}
+}
+
+// isDefStatementStart tells whether the next token starts an import, a variable declaration or a macro declaration
+func (p *parser) isDefStatementStart() bool {
+	if p.defLexer.nextTokenType() != token_symbol {
+		return false
+	}
+	switch p.defLexer.peekTokenType(1) {
+	case token_colon, token_define, token_brackets_left:
+		return true
+	case token_string:
+		return p.defLexer.currToken().text == "import"
+	}
+	return false
+}
+
+// parse_import declares the variables of the imported def file under its namespace, eg. common.platform for import "common_def.ss".
+// The namespace is the file name without extension and _def unless it is given by as.
+func (p *parser) parse_import(token *token) {
+	pathToken := p.checkDefToken(token_string)
+	path := literalValue("STRING", pathToken.text).text
+	namespace := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	namespace = strings.TrimSuffix(namespace, "_def")
+	if p.defLexer.nextTokenType() == token_symbol && p.defLexer.currToken().text == "as" && p.defLexer.peekTokenType(1) == token_symbol {
+		p.defLexer.takeToken()
+		namespace = p.checkDefToken(token_symbol).text
+	}
+	if !namespace_rule.MatchString(namespace) {
+		p.parseError(pathToken, fmt.Sprintf("invalid namespace %q of %s, use import %s as <name>", namespace, path, pathToken.text))
+	}
+	path = p.findImport(pathToken, path)
+	absPath, _ := filepath.Abs(path)
+	for i, v := range p.defFileList {
+		if absFile, _ := filepath.Abs(v); absFile == absPath {
+			cycle := append(append([]string{}, p.defFileList[i:]...), path)
+			p.parseError(pathToken, "import cycle: "+strings.Join(cycle, " -> "))
+		}
+	}
+	prefix := p.defPrefixList[len(p.defPrefixList)-1] + namespace + "."
+	if p.importedSet[absPath+":"+prefix] {
+		return
+	}
+	p.importedSet[absPath+":"+prefix] = true
+	file, err := os.Open(path)
+	if err != nil {
+		p.parseError(pathToken, fmt.Sprintf("cannot import def file: %s", err.Error()))
+	}
+	defer file.Close()
+	outer := p.defLexer
+	p.defLexer = newLexer("ss", path, file)
+	p.defFileList = append(p.defFileList, path)
+	p.defPrefixList = append(p.defPrefixList, prefix)
+	defer func() {
+		p.defLexer = outer
+		p.defFileList = p.defFileList[:len(p.defFileList)-1]
+		p.defPrefixList = p.defPrefixList[:len(p.defPrefixList)-1]
+	}()
+	p.diagnostics = append(p.diagnostics, p.defLexer.diagnostics...)
+	p.parseDef()
+}
+
+var namespace_rule = regexp.MustCompile(`^\w+$`)
+
+// findImport finds the imported file in the dir of the importing file, then in the import dirs given by -I
+func (p *parser) findImport(pathToken *token, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	dirs := append([]string{filepath.Dir(p.defLexer.fileName)}, p.importDirs...)
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			return filepath.Join(dir, path)
+		}
+	}
+	p.parseError(pathToken, fmt.Sprintf("cannot find def file %s in %s", path, strings.Join(dirs, ", ")))
+	return ""
+}
+
+func (p *parser) parse_var_declare(token *token) {
+	varName := p.defPrefixList[len(p.defPrefixList)-1] + token.text
+	_, ok := p.varDeclareSet[varName]
+	if ok {
+		p.parseError(token, fmt.Sprintf("variable %s has been declared", varName))
+	}
+	if _, ok := p.macroSet[varName]; ok {
+		p.parseError(token, fmt.Sprintf("%s has been declared as macro", varName))
+	}
+	varDeclare := &varDecl{token: token, name: varName, varType: "", valList: make([]string, 0), scope: "GLOBAL"}
+	p.varDeclareSet[varName] = varDeclare
+	p.varNameList = append(p.varNameList, varName)
+	p.checkDefToken(token_colon)
+	if p.defLexer.nextTokenType() == token_symbol {
+		p.parse_var_declare_type(varName)
+		switch {
+		case varDeclare.varType == "BOOL":
+			// bool variable is a value list of false and true, so that it is part of matrix
+			varDeclare.valList = []string{"false", "true"}
+		case p.defLexer.nextTokenType() == token_square_left:
+			p.parse_var_declare_range(varDeclare)
+			varDeclare.anyVal = true
+		case p.defLexer.nextTokenType() != token_brace_left:
+			varDeclare.anyVal = true
+		}
+	}
+	if !varDeclare.anyVal && varDeclare.varType != "BOOL" {
+		p.checkDefToken(token_brace_left)
+		p.parse_var_declare_val(varName)
+		p.checkDefToken(token_brace_right)
+	}
+	p.parse_var_declare_attrs(varDeclare)
+}
+
+// parse_var_declare_attrs parses the attributes after the declaration, they can be on the following lines:
+//
+//	mode: {"debug", "release"}
+//		default "debug"
+//		desc "build mode"
+func (p *parser) parse_var_declare_attrs(varDeclare *varDecl) {
+	optional := false
+	for p.defLexer.nextTokenType() == token_symbol && !p.isDefStatementStart() {
+		token := p.defLexer.takeToken()
+		switch token.text {
+		case "default":
+			if varDeclare.defaultToken != nil {
+				p.parseError(token, fmt.Sprintf("default of %s is given twice", varDeclare.name))
+			}
+			varDeclare.defaultToken = p.parse_var_value(p.defLexer, varDeclare, token)
+		case "required":
+			varDeclare.required = true
+		case "optional":
+			optional = true
+		case "desc":
+			varDeclare.desc = literalValue("STRING", p.checkDefToken(token_string).text).text
+		default:
+			p.parseError(token, fmt.Sprintf("unknown attribute %s of %s, supported attributes: default, required, optional, desc", token.text, varDeclare.name))
+		}
+	}
+	if varDeclare.required && optional {
+		p.parseError(varDeclare.token, fmt.Sprintf("variable %s is both required and optional", varDeclare.name))
+	}
+	if varDeclare.required && varDeclare.defaultToken != nil {
+		p.parseError(varDeclare.token, fmt.Sprintf("required variable %s should not have default", varDeclare.name))
+	}
+	if !optional && varDeclare.defaultToken == nil && !varDeclare.anyVal && len(varDeclare.valList) > 0 {
+		varDeclare.required = true
+	}
+}
+
+// var_types maps the declared types to value types, number is the same as int
+var var_types = map[string]string{
+	"string":  "STRING",
+	"number":  "INT",
+	"int":     "INT",
+	"float":   "FLOAT",
+	"bool":    "BOOL",
+	"version": "VERSION",
+}
+
+func (p *parser) parse_var_declare_type(varName string) {
+	token := p.defLexer.takeToken()
+	varType, ok := var_types[token.text]
+	if !ok {
+		p.parseError(token, fmt.Sprintf("unknown type %s, supported types: string, int, float, bool, version", token.text))
+	}
+	p.varDeclareSet[varName].varType = varType
+}
+
+// parse_var_declare_range parses the range of int or float variable, eg. [1..10], either bound can be omitted
+func (p *parser) parse_var_declare_range(varDeclare *varDecl) {
+	token := p.checkDefToken(token_square_left)
+	if varDeclare.varType != "INT" && varDeclare.varType != "FLOAT" {
+		p.parseError(token, fmt.Sprintf("range is only for int and float variables, but %s is %s", varDeclare.name, varDeclare.varType))
+	}
+	if p.defLexer.nextTokenType() == token_number {
+		varDeclare.rangeMin = p.parse_range_bound(varDeclare)
+	}
+	p.checkDefToken(token_range)
+	if p.defLexer.nextTokenType() == token_number {
+		varDeclare.rangeMax = p.parse_range_bound(varDeclare)
+	}
+	p.checkDefToken(token_square_right)
+	if varDeclare.rangeMin != nil && varDeclare.rangeMax != nil && numberValue(varDeclare.rangeMin.text) > numberValue(varDeclare.rangeMax.text) {
+		p.parseError(varDeclare.rangeMin, fmt.Sprintf("range of %s is empty: %s", varDeclare.name, varDeclare.rangeDesc()))
+	}
+}
+
+func (p *parser) parse_range_bound(varDeclare *varDecl) *token {
+	token := p.checkDefToken(token_number)
+	if varDeclare.varType == "INT" && numberType(token.text) != "INT" {
+		p.parseError(token, fmt.Sprintf("bound %s of int variable %s should be an integer", token.text, varDeclare.name))
+	}
+	return token
+}
+
+// isDeclaredVal tells whether the value is in the value list, numbers are compared by value
+func (v *varDecl) isDeclaredVal(val value) bool {
+	for _, text := range v.valList {
+		declared := literalValue(v.varType, text)
+		if declared.isNumber() && val.isNumber() {
+			if numberValue(declared.text) == numberValue(val.text) {
+				return true
+			}
+		} else if declared.text == val.text {
+			return true
+		}
+	}
+	return false
+}
+
+// undeclaredMessage is the error of a value that is not in the value list, with the nearest declared value
+func (v *varDecl) undeclaredMessage(text string) string {
+	m := fmt.Sprintf("value %s is not declared for %s; declared values: %s", text, v.describe(), strings.Join(v.valList, ", "))
+	if nearest := v.nearestVal(text); nearest != "" {
+		m += fmt.Sprintf("; did you mean %s?", nearest)
+	}
+	return m
+}
+
+// nearestVal is the declared value with the least edit distance to the text, empty when there is no value list
+func (v *varDecl) nearestVal(text string) string {
+	target := literalValue(v.varType, text).text
+	ret := ""
+	minDistance := -1
+	for _, declared := range v.valList {
+		distance := editDistance(target, literalValue(v.varType, declared).text)
+		if minDistance < 0 || distance < minDistance {
+			ret = declared
+			minDistance = distance
+		}
+	}
+	return ret
+}
+
+// editDistance is the Levenshtein distance of the runes
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// rangeDesc describes the range in messages, eg. [1..10]
+func (v *varDecl) rangeDesc() string {
+	ret := "["
+	if v.rangeMin != nil {
+		ret += v.rangeMin.text
+	}
+	ret += ".."
+	if v.rangeMax != nil {
+		ret += v.rangeMax.text
+	}
+	return ret + "]"
+}
+
+// inRange checks the number with the range of variable
+func (v *varDecl) inRange(text string) bool {
+	val := numberValue(text)
+	if v.rangeMin != nil && val < numberValue(v.rangeMin.text) {
+		return false
+	}
+	if v.rangeMax != nil && val > numberValue(v.rangeMax.text) {
+		return false
+	}
+	return true
+}
+
+func numberValue(text string) float64 {
+	ret, _ := strconv.ParseFloat(text, 64)
+	return ret
+}
+
+func (p *parser) parse_var_declare_val(varName string) {
+	if p.defLexer.nextTokenType() != token_number && p.defLexer.nextTokenType() != token_string {
+		return
+	}
+	token := p.defLexer.takeToken()
+	if token.tokenType == token_number {
+		p.addGlobalVarVal(varName, numberType(token.text), token)
+	} else if token.tokenType == token_string {
+		p.addGlobalVarVal(varName, "STRING", token)
+	}
+	if p.defLexer.nextTokenType() == token_comma {
+		p.checkDefToken(token_comma)
+		p.parse_var_declare_val(varName)
+	}
+}
+
+func (p *parser) addGlobalVarVal(varName string, varType string, token *token) {
+	varDeclare := p.varDeclareSet[varName]
+	// version values are written as strings
+	if varDeclare.varType == "VERSION" && varType == "STRING" {
+		if _, err := parseVersion(literalValue(varType, token.text).text); err != nil {
+			p.addError(token, err.Error())
+			return
+		}
+		varType = "VERSION"
+	}
+	// int values of float variable
+	if varDeclare.varType == "FLOAT" && varType == "INT" {
+		varType = "FLOAT"
+	}
+	if varDeclare.varType != "" && varDeclare.varType != varType {
+		p.addError(token, fmt.Sprintf("value %s of %s is %s, but previous values are %s", token.text, varName, varType, varDeclare.varType))
+		return
+	}
+	varDeclare.varType = varType
+
+	varDeclare.valList = append(varDeclare.valList, token.text)
+	//log.Println("Global varible ", varName, varType, token.text)
+}
+
+func (p *parser) parseConfig() {
+	for p.configLexer.nextTokenType() != -1 {
+		p.parse_config_statement()
+	}
+}
+
+// parseConfigLayer assigns the variables of a config layer over the former layers, eg. -D flags over the config file.
+// It is also used by extends, the lexer of the outer config is restored after the base config is parsed.
+func (p *parser) parseConfigLayer(lexer *lexer) {
+	outer := p.configLexer
+	p.configLexer = lexer
+	p.configFileList = append(p.configFileList, lexer.fileName)
+	defer func() {
+		p.configLexer = outer
+		p.configFileList = p.configFileList[:len(p.configFileList)-1]
+	}()
+	p.diagnostics = append(p.diagnostics, lexer.diagnostics...)
+	p.parseConfig()
+}
+
+func (p *parser) parse_config_statement() {
+	defer p.recoverStatement(p.configLexer, func() bool {
+		return p.configLexer.nextTokenType() == token_symbol &&
+			(p.configLexer.peekTokenType(1) == token_assign || p.configLexer.peekTokenType(1) == token_string)
+	})
+	token := p.configLexer.takeToken()
+	switch token.tokenType {
+	case token_symbol:
+		if token.text == "extends" && p.configLexer.nextTokenType() == token_string {
+			p.parse_extends(token)
+			return
+		}
+		p.parse_assign(token)
+	default:
+		p.parseError(token, "syntax error: unexpected "+tokenDesc(token))
+	}
+}
+
+// parse_extends assigns the base config first, so that the assigns of the current file override it.
+// The path is relative to the current config file, the base config can be of any config format.
+func (p *parser) parse_extends(token *token) {
+	pathToken := p.checkConfigToken(token_string)
+	for _, v := range p.configLexer.tokens[:p.configLexer.currTokenIdx-2] {
+		if v.tokenType == token_assign {
+			p.parseError(token, "extends should be before the assigns of config")
+		}
+	}
+	path := literalValue("STRING", pathToken.text).text
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.configLexer.fileName), path)
+	}
+	absPath, _ := filepath.Abs(path)
+	for i, v := range p.configFileList {
+		if absFile, _ := filepath.Abs(v); absFile == absPath {
+			cycle := append(append([]string{}, p.configFileList[i:]...), path)
+			p.parseError(pathToken, "extends cycle: "+strings.Join(cycle, " -> "))
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		p.parseError(pathToken, fmt.Sprintf("cannot load base config: %s", err.Error()))
+	}
+	defer file.Close()
+	p.parseConfigLayer(newConfigLexer(path, file))
+}
+
+// applyDefaults assigns the default values to the variables that are not assigned by any config layer
+func (p *parser) applyDefaults() {
+	for _, name := range p.varNameList {
+		varDeclare := p.varDeclareSet[name]
+		if varDeclare.currVal == "" && varDeclare.defaultToken != nil {
+			varDeclare.currVal = varDeclare.defaultToken.text
+			varDeclare.valToken = varDeclare.defaultToken
+		}
+	}
+}
+
+// checkRequired reports the required variables that are not assigned by any config layer,
+// it is not checked by matrix and coverage, which assign every value of them
+func (p *parser) checkRequired() {
+	for _, name := range p.varNameList {
+		varDeclare := p.varDeclareSet[name]
+		if !varDeclare.required || varDeclare.currVal != "" {
+			continue
+		}
+		m := fmt.Sprintf("required variable %s is not assigned by any config", varDeclare.describe())
+		if len(varDeclare.valList) > 0 {
+			m += "; declared values: " + strings.Join(varDeclare.valList, ", ")
+		}
+		p.addError(varDeclare.token, m)
+	}
+}
+
+func (p *parser) parse_assign(token *token) {
+	varName := token.text
+	varDeclare, ok := p.varDeclareSet[varName]
+	if !ok {
+		p.parseError(token, fmt.Sprintf("variable %s is not declared", varName))
+	}
+	p.checkConfigToken(token_assign)
+	valToken := p.parse_var_value(p.configLexer, varDeclare, token)
+	varDeclare.currVal = valToken.text
+	varDeclare.valToken = valToken
+
+	//log.Println(varDeclare.name, varDeclare.currVal)
+}
+
+// parse_var_value takes the value assigned by config or default, and checks it with the declaration of variable,
+// nameToken is the variable name of config or the default attribute
+func (p *parser) parse_var_value(lexer *lexer, varDeclare *varDecl, nameToken *token) *token {
+	var valToken *token
+	if lexer.nextTokenType() == token_bare {
+		valToken = p.convertBareValue(varDeclare, lexer.takeToken())
+	} else {
+		switch varDeclare.varType {
+		case "INT", "FLOAT":
+			valToken = p.checkToken(lexer, token_number)
+		case "BOOL":
+			valToken = p.checkToken(lexer, token_symbol)
+		case "STRING", "VERSION":
+			valToken = p.checkToken(lexer, token_string)
+		default:
+			p.parseError(nameToken, fmt.Sprintf("variable %s has no declared value", varDeclare.name))
+		}
+	}
+	switch varDeclare.varType {
+	case "INT":
+		if numberType(valToken.text) != "INT" {
+			p.parseError(valToken, fmt.Sprintf("value %s of %s should be an integer", valToken.text, varDeclare.describe()))
+		}
+	case "BOOL":
+		if valToken.text != "true" && valToken.text != "false" {
+			p.parseError(valToken, fmt.Sprintf("value %s of %s should be true or false", valToken.text, varDeclare.describe()))
+		}
+	}
+	if varDeclare.anyVal {
+		if varDeclare.varType == "VERSION" {
+			if _, err := parseVersion(literalValue("VERSION", valToken.text).text); err != nil {
+				p.parseError(valToken, err.Error())
+			}
+		}
+		if (varDeclare.varType == "INT" || varDeclare.varType == "FLOAT") && !varDeclare.inRange(valToken.text) {
+			p.parseError(valToken, fmt.Sprintf("value %s of %s is out of range %s", valToken.text, varDeclare.describe(), varDeclare.rangeDesc()))
+		}
+		return valToken
+	}
+	isDeclare := false
+	for _, v := range varDeclare.valList {
+		if v == valToken.text {
+			isDeclare = true
+			break
+		}
+	}
+	if isDeclare == false {
+		p.parseError(valToken, varDeclare.undeclaredMessage(valToken.text))
+	}
+	return valToken
+}
+
+// parseCondition parses a condition out of source files, eg. the filter of matrix, nil is returned on error
+func (p *parser) parseCondition(lexer *lexer) exprNode {
+	p.sourceLexer = lexer
+	p.diagnostics = append(p.diagnostics, lexer.diagnostics...)
+	if lexer.nextTokenType() == -1 {
+		p.addError(lexer.eofToken(), "condition is empty")
+		return nil
+	}
+	var expr exprNode
+	func() {
+		defer p.recoverStatement(lexer, func() bool { return false })
+		expr = p.parse_logic_expr(1)
+		if token := lexer.currToken(); token != nil {
+			p.parseError(token, "syntax error: unexpected "+tokenDesc(token))
+		}
+		p.checkLiterals(expr)
+	}()
+	if p.hasError() {
+		return nil
+	}
+	return expr
+}
+
+// convertBareValue converts the unquoted value of structured config to the token of the declared type
+func (p *parser) convertBareValue(varDeclare *varDecl, token *token) *token {
+	ret := *token
+	switch varDeclare.varType {
+	case "INT", "FLOAT":
+		// a float assigned to int variable is reported by parse_var_value
+		if !bare_number_rule.MatchString(token.text) {
+			p.parseError(token, fmt.Sprintf("syntax error: expected %s, got %s", tokenTypeDesc(token_number), tokenDesc(token)))
+		}
+		ret.tokenType = token_number
+	case "BOOL":
+		ret.tokenType = token_symbol
+	case "STRING", "VERSION":
+		ret.tokenType = token_string
+		ret.text = strconv.Quote(token.text)
+	default:
+		p.parseError(token, fmt.Sprintf("variable %s has no declared value", varDeclare.name))
+	}
+	return &ret
+}
+
+func (p *parser) parseSourceCode(sourceLexer *lexer) {
+	p.sourceLexer = sourceLexer
+	p.soscriptList = make([]*soscriptBlock, 0)
+	p.ifBlockList = make([]*ifBlock, 0)
+	p.diagnostics = append(p.diagnostics, p.sourceLexer.diagnostics...)
+	for p.sourceLexer.nextTokenType() != -1 {
+		p.parse_source_statement()
+	}
+}
+
+// parseSourceStream parses the source of streaming lexer block by block, flush is called with each parsed block before the lexer reads on,
+// so that the block can be written right after the text before it. The blocks are not kept, their lines are released after flush
+func (p *parser) parseSourceStream(sourceLexer *lexer, flush func(soscript *soscriptBlock, block *ifBlock)) {
+	p.sourceLexer = sourceLexer
+	for sourceLexer.nextTokenType() != -1 {
+		p.soscriptList = make([]*soscriptBlock, 0)
+		p.ifBlockList = make([]*ifBlock, 0)
+		p.diagnostics = append(p.diagnostics, sourceLexer.takeDiagnostics()...)
+		p.parse_source_statement()
+		p.diagnostics = append(p.diagnostics, sourceLexer.takeDiagnostics()...)
+		if len(p.soscriptList) > 0 {
+			flush(p.soscriptList[0], nil)
+		} else if len(p.ifBlockList) > 0 {
+			flush(nil, p.ifBlockList[0])
+		}
+		sourceLexer.release()
+	}
+	p.diagnostics = append(p.diagnostics, sourceLexer.takeDiagnostics()...)
+}
+
+func (p *parser) parse_source_statement() {
+	defer p.recoverStatement(p.sourceLexer, func() bool {
+		return p.sourceLexer.nextTokenType() == tag_soscript_start || p.sourceLexer.nextTokenType() == tag_if_start
+	})
+	token := p.sourceLexer.takeToken()
+	//log.Println(token.lineno, token.tokenType, token.text)
+	switch token.tokenType {
+	case tag_soscript_start:
+		p.parse_soscript(token)
+	case tag_if_start:
+		p.parse_if_block(token)
+	default:
+		p.parseError(token, "syntax error: unexpected "+tokenDesc(token))
+	}
+}
+
+func (p *parser) parse_soscript(token *token) {
+	soscript := &soscriptBlock{startLineno: token.lineno, varDeclareSet: make(map[string]*varDecl, 0)}
+	p.soscriptList = append(p.soscriptList, soscript)
+	soscript.defaultStartLineno = p.checkSourceToken(tag_default_start).lineno
+	for p.sourceLexer.nextTokenType() == token_origin {
+		soscript.originLines = append(soscript.originLines, p.sourceLexer.takeToken().text)
+	}
+	soscript.defaultEndLineno = p.checkSourceToken(tag_default_end).lineno
+	for p.sourceLexer.nextTokenType() == tag_line_start {
+		p.parse_soscript_line_tag(soscript)
+	}
+	soscript.endLineno = p.checkSourceToken(tag_soscript_end).lineno
+	p.evalSoscript(soscript)
+}
+
+func (p *parser) parse_soscript_line_tag(soscript *soscriptBlock) {
+	defer p.recoverStatement(p.sourceLexer, func() bool {
+		switch p.sourceLexer.nextTokenType() {
+		case tag_line_start, tag_soscript_end, tag_soscript_start:
+			return true
+		}
+		return false
+	})
+	p.checkSourceToken(tag_line_start)
+	p.parse_soscript_line(soscript)
+	p.checkSourceToken(tag_line_end)
+}
+
+func (p *parser) parse_soscript_line(soscript *soscriptBlock) {
+	for p.sourceLexer.nextTokenType() != -1 && p.sourceLexer.nextTokenType() != tag_line_end {
+		token := p.sourceLexer.takeToken()
+		switch token.tokenType {
+		case token_symbol:
+			if p.sourceLexer.nextTokenType() == token_assign {
+				p.parse_soscript_assign(soscript, token)
+			}
+		case token_keyword_if:
+			p.parse_soscript_if(soscript, token, line_type_if)
+		case token_keyword_elif:
+			p.checkBranchChain(soscript, token)
+			p.parse_soscript_if(soscript, token, line_type_elif)
+		case token_keyword_else:
+			p.checkBranchChain(soscript, token)
+			p.parse_soscript_else(soscript, token)
+		default:
+			p.parseError(token, "syntax error: unexpected "+tokenDesc(token))
+		}
+	}
+}
+
+// checkBranchChain checks that elif or else follows an if or elif, assign lines between them are allowed
+func (p *parser) checkBranchChain(soscript *soscriptBlock, token *token) {
+	for i := len(soscript.lineList) - 1; i >= 0; i-- {
+		switch soscript.lineList[i].lineType {
+		case line_type_if, line_type_elif:
+			return
+		case line_type_else:
+			p.parseError(token, fmt.Sprintf("%s after else of line %d", token.text, soscript.lineList[i].token.lineno))
+		}
+	}
+	p.parseError(token, token.text+" without if")
+}
+
+func (p *parser) parse_soscript_assign(soscript *soscriptBlock, token *token) {
+	p.checkSourceToken(token_assign)
+	expr := p.parse_logic_expr(1)
+	p.checkLiterals(expr)
+	soscript.lineList = append(soscript.lineList, &soscriptLine{token: token, lineType: line_type_assign, name: token.text, expr: expr})
+}
+
+func (p *parser) parse_soscript_if(soscript *soscriptBlock, token *token, lineType int) {
+	p.checkSourceToken(token_brackets_left)
+	expr := p.parse_logic_expr(1)
+	p.checkSourceToken(token_brackets_right)
+	p.checkLiterals(expr)
+	soscript.lineList = append(soscript.lineList, newCodeLine(token, lineType, expr, p.parse_print_expr()))
+}
+
+func (p *parser) parse_soscript_else(soscript *soscriptBlock, token *token) {
+	soscript.lineList = append(soscript.lineList, newCodeLine(token, line_type_else, nil, p.parse_print_expr()))
+}
+
+func newCodeLine(token *token, lineType int, expr exprNode, codeToken *token) *soscriptLine {
+	line := &soscriptLine{token: token, lineType: lineType, expr: expr, codeToken: codeToken}
+	if codeToken != nil {
+		line.code = codeToken.text
+	}
+	return line
+}
+
+// parse_print_expr returns the code token, nil when the code is empty
+func (p *parser) parse_print_expr() *token {
+	p.checkSourceToken(token_keyword_print)
+	p.checkSourceToken(token_brackets_left)
+	p.checkSourceToken(tag_code_start)
+	code := p.parse_code_expr()
+	p.checkSourceToken(tag_code_end)
+	p.checkSourceToken(token_brackets_right)
+	return code
+}
+
+func (p *parser) parse_if_block(token *token) {
+	block := &ifBlock{startLineno: token.lineno}
+	p.ifBlockList = append(p.ifBlockList, block)
+	block.branchList = append(block.branchList, p.parse_if_branch(token))
+	for {
+		token := p.takeSourceToken()
+		switch token.tokenType {
+		case tag_elif_start:
+			if block.branchList[len(block.branchList)-1].expr == nil {
+				p.parseError(token, "<elif> after <else>")
+			}
+			block.branchList = append(block.branchList, p.parse_if_branch(token))
+		case tag_else:
+			if block.branchList[len(block.branchList)-1].expr == nil {
+				p.parseError(token, "<else> after <else>")
+			}
+			block.branchList = append(block.branchList, &ifBranch{token: token})
+		case tag_if_end:
+			block.endLineno = token.lineno
+			p.evalIfBlock(block)
+			return
+		case tag_if_start, tag_soscript_start:
+			p.parseError(token, fmt.Sprintf("missing </if> of line %d, if block can not be nested", block.startLineno))
+		default:
+			p.parseError(token, fmt.Sprintf("syntax error: expected </if> of line %d, got %s", block.startLineno, tokenDesc(token)))
+		}
+	}
+}
+
+func (p *parser) parse_if_branch(token *token) *ifBranch {
+	expr := p.parse_logic_expr(1)
+	p.checkSourceToken(tag_cond_end)
+	p.checkLiterals(expr)
+	return &ifBranch{token: token, expr: expr}
+}
+
+// binary_precedence is the precedence of binary operators, the higher binds tighter
+var binary_precedence = map[int]int{
+	token_keyword_or:     1,
+	token_keyword_and:    2,
+	token_equal:          3,
+	token_not_equal:      3,
+	token_keyword_in:     3,
+	token_keyword_not_in: 3,
+	token_great:          4,
+	token_less:           4,
+	token_great_equal:    4,
+	token_less_equal:     4,
+}
+
+// parse_logic_expr parses binary operations by precedence climbing,
+// only operators with precedence not lower than minPrecedence are taken
+func (p *parser) parse_logic_expr(minPrecedence int) exprNode {
+	left := p.parse_unary_expr()
+	for {
+		precedence, ok := binary_precedence[p.sourceLexer.nextTokenType()]
+		if !ok || precedence < minPrecedence {
+			return left
+		}
+		token := p.sourceLexer.takeToken()
+		if token.tokenType == token_keyword_in || token.tokenType == token_keyword_not_in {
+			left = p.parse_in_expr(token, left)
+			continue
+		}
+		// all binary operators are left associative
+		right := p.parse_logic_expr(precedence + 1)
+		left = &binaryExpr{token: token, op: token.tokenType, left: left, right: right}
+	}
+}
+
+func (p *parser) parse_in_expr(token *token, left exprNode) exprNode {
+	expr := &inExpr{token: token, not: token.tokenType == token_keyword_not_in, left: left}
+	p.checkSourceToken(token_brackets_left)
+	for {
+		expr.list = append(expr.list, p.parse_logic_expr(1))
+		if p.sourceLexer.nextTokenType() != token_comma {
+			break
+		}
+		p.checkSourceToken(token_comma)
+	}
+	p.checkSourceToken(token_brackets_right)
+	return expr
+}
+
+// checkLiterals checks the literals compared with global variables by ==, != and in against the declared values,
+// so that the comparison that never changes with config is reported. The literals of macros are checked after the names in macros are resolved.
+func (p *parser) checkLiterals(expr exprNode) {
+	p.checkLiteralsIn(expr, nil, make(map[*literalExpr]bool, 0))
+}
+
+// checkLiteralsIn checks the literals of expr, only the literals in args are checked when args is not nil, and a literal is reported once.
+// A macro call is checked with its expansion, so the literal given to a param is reported at the call site, eg. isPlat("andriod")
+func (p *parser) checkLiteralsIn(expr exprNode, args map[*literalExpr]bool, reported map[*literalExpr]bool) {
+	walkExpr(expr, func(expr exprNode) {
+		switch e := expr.(type) {
+		case *binaryExpr:
+			if e.op == token_equal || e.op == token_not_equal {
+				p.checkLiteral(e.left, e.right, args, reported)
+				p.checkLiteral(e.right, e.left, args, reported)
+			}
+		case *inExpr:
+			for _, item := range e.list {
+				p.checkLiteral(e.left, item, args, reported)
+			}
+		case *callExpr:
+			m := p.macroSet[e.name]
+			if m == nil || m.invalid || len(e.args) != len(m.params) {
+				return
+			}
+			callArgs := make(map[*literalExpr]bool, 0)
+			for _, arg := range e.args {
+				walkExpr(arg, func(expr exprNode) {
+					if literal, ok := expr.(*literalExpr); ok && (args == nil || args[literal]) {
+						callArgs[literal] = true
+					}
+				})
+			}
+			if len(callArgs) > 0 {
+				p.checkLiteralsIn(m.expand(e.args), callArgs, reported)
+			}
+		}
+	})
+}
+
+// checkLiteral reports the literal that can never be the value of the variable, other expressions are not checked
+func (p *parser) checkLiteral(varExpr exprNode, otherExpr exprNode, args map[*literalExpr]bool, reported map[*literalExpr]bool) {
+	ident, ok := unparenExpr(varExpr).(*identExpr)
+	if !ok {
+		return
+	}
+	literal, ok := unparenExpr(otherExpr).(*literalExpr)
+	if !ok || reported[literal] || (args != nil && !args[literal]) {
+		return
+	}
+	reported[literal] = true
+	varDeclare, ok := p.varDeclareSet[ident.name]
+	if !ok {
+		return
+	}
+	val := literalValue(literal.valType, literal.text)
+	if varDeclare.anyVal {
+		if (varDeclare.varType == "INT" || varDeclare.varType == "FLOAT") && val.isNumber() && !varDeclare.inRange(val.text) {
+			p.addError(literal.token, fmt.Sprintf("value %s is out of range %s of %s", literal.text, varDeclare.rangeDesc(), varDeclare.describe()))
+		}
+		return
+	}
+	if len(varDeclare.valList) > 0 && !varDeclare.isDeclaredVal(val) {
+		p.addError(literal.token, varDeclare.undeclaredMessage(literal.text))
+	}
+}
+
+func (p *parser) parse_unary_expr() exprNode {
+	if p.sourceLexer.nextTokenType() == token_keyword_not {
+		token := p.sourceLexer.takeToken()
+		return &unaryExpr{token: token, op: token.tokenType, operand: p.parse_unary_expr()}
+	}
+	return p.parse_primary_expr()
+}
+
+func (p *parser) parse_primary_expr() exprNode {
+	token := p.takeSourceToken()
+	switch token.tokenType {
+	case token_symbol:
+		if p.sourceLexer.nextTokenType() == token_brackets_left {
+			return p.parse_call_expr(token)
+		}
+		if token.text == "true" || token.text == "false" {
+			return &literalExpr{token: token, valType: "BOOL", text: token.text}
+		}
+		return &identExpr{token: token, name: token.text}
+	case token_string:
+		return &literalExpr{token: token, valType: "STRING", text: token.text}
+	case token_number:
+		return &literalExpr{token: token, valType: numberType(token.text), text: token.text}
+	case token_brackets_left:
+		inner := p.parse_logic_expr(1)
+		p.checkSourceToken(token_brackets_right)
+		return &parenExpr{token: token, inner: inner}
+	}
+	p.parseError(token, "syntax error: expected expression, got "+tokenDesc(token))
+	return nil
+}
+
+func (p *parser) parse_call_expr(token *token) exprNode {
+	call := &callExpr{token: token, name: token.text}
+	p.checkSourceToken(token_brackets_left)
+	for p.sourceLexer.nextTokenType() != token_brackets_right {
+		if len(call.args) > 0 {
+			p.checkSourceToken(token_comma)
+		}
+		call.args = append(call.args, p.parse_logic_expr(1))
+	}
+	p.checkSourceToken(token_brackets_right)
+	return call
+}
+
+func (p *parser) parse_code_expr() *token {
+	if p.sourceLexer.nextTokenType() != token_code {
+		return nil
+	}
+	return p.sourceLexer.takeToken()
+}
+
+// evalSoscript evaluates the lines of soscript block with the current config.
+// Block variables are assigned in order, the first true branch of an if chain is selected,
+// and it is an error that more than one chain is selected.
+func (p *parser) evalSoscript(soscript *soscriptBlock) {
+	env := &soscriptEnv{parser: p, soscript: soscript}
+	soscript.varDeclareSet = make(map[string]*varDecl, 0)
+	soscript.code = ""
+	soscript.matched = false
+	var selectedLine *soscriptLine
+	chainMatched := false
+	for _, line := range soscript.lineList {
+		line.selected = false
+		if line.lineType == line_type_if {
+			chainMatched = false
+		}
+		val := boolValue(true)
+		if line.expr != nil {
+			var err *evalError
+			val, err = evalExpr(line.expr, env)
+			if err != nil {
+				p.addError(err.token, err.message)
+				continue
+			}
+		}
+		line.val = val
+		if line.lineType == line_type_assign {
+			soscript.varDeclareSet[line.name] = &varDecl{name: line.name, varType: val.valType, currVal: val.String(), scope: "BLOCK", valToken: line.token}
+			continue
+		}
+		if val.valType != "BOOL" {
+			p.addError(line.expr.exprToken(), fmt.Sprintf("condition is %s, but BOOL is expected", val.valType))
+			continue
+		}
+		if !chainMatched && val.isTrue() {
+			chainMatched = true
+			line.selected = true
+		}
+		code := p.interpolate(line, env)
+		if !line.selected {
+			continue
+		}
+		if selectedLine != nil {
+			p.addError(line.token, fmt.Sprintf("both line %d and line %d are true, use elif or else to make them exclusive", selectedLine.token.lineno, line.token.lineno))
+			continue
+		}
+		selectedLine = line
+		soscript.code = code
+		soscript.matched = true
+	}
+}
+
+// evalIfBlock selects the first true branch of if block with the current config
+func (p *parser) evalIfBlock(block *ifBlock) {
+	env := &soscriptEnv{parser: p}
+	block.selected = nil
+	for _, branch := range block.branchList {
+		branch.selected = false
+		val := boolValue(true)
+		if branch.expr != nil {
+			var err *evalError
+			val, err = evalExpr(branch.expr, env)
+			if err != nil {
+				p.addError(err.token, err.message)
+				continue
+			}
+			if val.valType != "BOOL" {
+				p.addError(branch.expr.exprToken(), fmt.Sprintf("condition is %s, but BOOL is expected", val.valType))
+				continue
+			}
+		}
+		branch.val = val
+		if block.selected == nil && val.isTrue() {
+			branch.selected = true
+			block.selected = branch
+		}
+	}
+}
+
+// soscriptEnv looks up the global variables first and then the block variables,
+// soscript is nil when there is no block variable
+type soscriptEnv struct {
+	parser   *parser
+	soscript *soscriptBlock
+}
+
+func (env *soscriptEnv) lookup(name string) (value, bool) {
+	varDeclare := env.find(name)
+	if varDeclare == nil {
+		return value{}, false
+	}
+	return literalValue(varDeclare.varType, varDeclare.currVal), true
+}
+
+func (env *soscriptEnv) macro(name string) *macro {
+	return env.parser.macroSet[name]
+}
+
+func (env *soscriptEnv) find(name string) *varDecl {
+	varDeclare, ok := env.parser.varDeclareSet[name]
+	if !ok && env.soscript != nil {
+		varDeclare, ok = env.soscript.varDeclareSet[name]
+	}
+	if !ok {
+		return nil
+	}
+	return varDeclare
+}
+
+// takeSourceToken is the same as takeToken but returns an EOF token at the end of file
+func (p *parser) takeSourceToken() *token {
+	token := p.sourceLexer.takeToken()
+	if token == nil {
+		return p.sourceLexer.eofToken()
+	}
+	return token
+}
+
+// checkToken takes the next token of the expected type,
+// the unexpected token is left to the lexer so that the parser can recover from it
+func (p *parser) checkToken(lexer *lexer, tokenType int) *token {
+	token := lexer.currToken()
+	if token == nil {
+		token = lexer.eofToken()
+	}
+	if token.tokenType != tokenType {
+		p.parseError(token, fmt.Sprintf("syntax error: expected %s, got %s", tokenTypeDesc(tokenType), tokenDesc(token)))
+	}
+	lexer.takeToken()
+	//log.Println("checkToken", token.lineno, token.text)
+	return token
+}
+
+func (p *parser) checkDefToken(tokenType int) *token {
+	return p.checkToken(p.defLexer, tokenType)
+}
+
+func (p *parser) checkConfigToken(tokenType int) *token {
+	return p.checkToken(p.configLexer, tokenType)
+}
+
+func (p *parser) checkSourceToken(tokenType int) *token {
+	return p.checkToken(p.sourceLexer, tokenType)
+}
+
+func (p *parser) addDiagnostic(severity string, token *token, m string) {
+	fileName := ""
+	snippet := ""
+	if token.lexer != nil {
+		fileName = token.lexer.fileName
+		snippet = token.lexer.line(token.lineno)
+	}
+	p.diagnostics = append(p.diagnostics, newDiagnostic(severity, fileName, token.lineno, token.column, m, snippet))
+}
+
+func (p *parser) addError(token *token, m string) {
+	p.addDiagnostic(SEVERITY_ERROR, token, m)
+}
+
+// parseError records an error and aborts the current statement
+func (p *parser) parseError(token *token, m string) {
+	p.addError(token, m)
+	panic(parseAbort{})
+}
+
+// recoverStatement recovers from parseError, the tokens left in the broken statement are skipped until sync returns true
+func (p *parser) recoverStatement(lexer *lexer, sync func() bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if _, ok := r.(parseAbort); !ok {
+		panic(r)
+	}
+	for lexer.nextTokenType() != -1 && !sync() {
+		lexer.takeToken()
+	}
+}
+
+func (p *parser) hasError() bool {
+	return hasDiagnosticError(p.diagnostics)
+}
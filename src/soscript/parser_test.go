@@ -0,0 +1,82 @@
+package soscript
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// compileTest compiles the source with the config over the defs
+func compileTest(t *testing.T, defs string, config string, source string) (string, error) {
+	t.Helper()
+	cfg := loadTestConfig(t, defs, config)
+	var out bytes.Buffer
+	err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "src.java"})
+	return out.String(), err
+}
+
+const chainSource = `// <soscript>
+// <default>
+channel = "none";
+// </default>
+// <line> if (platform == "android") print(<code> channel = "google"; </code>) </line>
+// <line> elif (platform == "ios") print(<code> channel = "appstore"; </code>) </line>
+// <line> else print(<code> channel = "web"; </code>) </line>
+// </soscript>
+`
+
+func TestElifElse(t *testing.T) {
+	for platform, want := range map[string]string{"android": "google", "ios": "appstore", "h5": "web", "pc": "web"} {
+		output, err := compileTest(t, testDefs, `platform = "`+platform+`"`, chainSource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `channel = "` + want + `";` + "\n"; output != want {
+			t.Errorf("%s: got %q, want %q", platform, output, want)
+		}
+	}
+}
+
+// two chains both true with the config is an error
+func TestConflictingIf(t *testing.T) {
+	source := strings.Replace(chainSource, "elif (platform == \"ios\")", "if (platform != \"ios\")", 1)
+	_, err := compileTest(t, testDefs, `platform = "android"`, source)
+	checkDiagnostics(t, err, []string{
+		"src.java:6: both line 5 and line 6 are true, use elif or else to make them exclusive",
+	})
+}
+
+// the sample at the top of README compiles with every config
+func TestReadmeSample(t *testing.T) {
+	sample, err := os.ReadFile("testdata/readme.java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(readme, sample) {
+		t.Fatal("testdata/readme.java is not the sample of README.md")
+	}
+	defs, err := LoadDefsFile("testdata/readme.ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, config := range []string{
+		"platform=android\nversion=1.0.1\nmode=release",
+		"platform=ios\nversion=1.0.2\nmode=release",
+		"platform=h5\nversion=1.0.3\nmode=debug\naddr=http://h5.example.com",
+	} {
+		cfg := defs.NewConfig()
+		cfg.Set(strings.Split(config, "\n")...)
+		var out bytes.Buffer
+		if err := Compile(bytes.NewReader(sample), &out, cfg, &Options{Name: "readme.java"}); err != nil {
+			t.Fatalf("%s: %v", config, err)
+		}
+		if want := "let serverAddr = \"http://localhost:8888\"\n"; out.String() != want {
+			t.Errorf("%s: got %q, want %q", config, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package soscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keywords are the words lexed as keywords, other words are identifiers.
+// not is a keyword only in not in, so that it can still be a variable name
+var keywords = map[string]int{
+	"if":    token_keyword_if,
+	"elif":  token_keyword_elif,
+	"else":  token_keyword_else,
+	"print": token_keyword_print,
+	"in":    token_keyword_in,
+}
+
+// scanner scans the soscript tokens of a line rune by rune
+type scanner struct {
+	lexer  *lexer
+	lineno int
+	src    string
+	offset int // byte offset of src in the whole line, columns are counted from the line start
+	pos    int // byte offset of the next rune in src
+}
+
+func newScanner(lexer *lexer, lineno int, offset int, src string) *scanner {
+	return &scanner{lexer: lexer, lineno: lineno, src: src, offset: offset}
+}
+
+// column is the column of byte offset pos of src in the whole line
+func (s *scanner) column(pos int) int {
+	return s.offset + pos + 1
+}
+
+// peek returns the rune n bytes after pos, or -1 at the end of src
+func (s *scanner) peek(n int) rune {
+	if s.pos+n >= len(s.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[s.pos+n:])
+	return r
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		s.pos += size
+	}
+}
+
+// skipWord skips the runes before the next space
+func (s *scanner) skipWord() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if unicode.IsSpace(r) {
+			return
+		}
+		s.pos += size
+	}
+}
+
+func (s *scanner) error(pos int, m string) {
+	s.lexer.error(s.lineno, s.column(pos), m)
+}
+
+// invalid reports the word starts at pos and skips it, so that the tokens after it are not lost
+func (s *scanner) invalid(pos int, format string) {
+	s.pos = pos
+	s.skipWord()
+	s.error(pos, fmt.Sprintf(format, s.src[pos:s.pos]))
+}
+
+func (s *scanner) token(pos int, tokenType int) *token {
+	return &token{lineno: s.lineno, column: s.column(pos), tokenType: tokenType, text: s.src[pos:s.pos], lexer: s.lexer}
+}
+
+// scan returns the next token, nil is returned at the end of src. Invalid tokens are reported and skipped
+func (s *scanner) scan() *token {
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return nil
+		}
+		if token := s.scanToken(); token != nil {
+			return token
+		}
+	}
+}
+
+func (s *scanner) scanToken() *token {
+	start := s.pos
+	c := s.peek(0)
+	// tags are scanned before operators so that <line> is not taken as <
+	if c == '<' {
+		for tagType := tag_soscript_start; tagType < tag_cond_end; tagType++ {
+			if n := tagPrefixLen(s.src[start:], tagType); n > 0 {
+				s.pos += n
+				return s.token(start, tagType)
+			}
+		}
+	}
+	switch {
+	case c == '"':
+		return s.scanString()
+	case isDigit(c) || (c == '-' && isDigit(s.peek(1))):
+		return s.scanNumber()
+	case c == '_' || unicode.IsLetter(c):
+		return s.scanWord()
+	}
+	// operators and punctuations, the two-rune ones first
+	if c < utf8.RuneSelf && s.pos+1 < len(s.src) {
+		if tokenType, ok := operator_pairs[s.src[s.pos:s.pos+2]]; ok {
+			s.pos += 2
+			return s.token(start, tokenType)
+		}
+	}
+	if tokenType, ok := operators[c]; ok {
+		s.pos += utf8.RuneLen(c)
+		return s.token(start, tokenType)
+	}
+	s.invalid(start, "invalid token %q")
+	return nil
+}
+
+var operator_pairs = map[string]int{
+	"//": token_comment,
+	":=": token_define,
+	"==": token_equal,
+	"!=": token_not_equal,
+	">=": token_great_equal,
+	"<=": token_less_equal,
+	"&&": token_keyword_and,
+	"||": token_keyword_or,
+	"..": token_range,
+}
+
+var operators = map[rune]int{
+	'>': token_great,
+	'<': token_less,
+	'=': token_assign,
+	',': token_comma,
+	':': token_colon,
+	'{': token_brace_left,
+	'}': token_brace_right,
+	'(': token_brackets_left,
+	')': token_brackets_right,
+	'[': token_square_left,
+	']': token_square_right,
+	'!': token_keyword_not,
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// scanWord scans an identifier or a keyword, eg. platform, common.platform, if.
+// The whole word is taken before keywords are checked, so ifMode is an identifier
+func (s *scanner) scanWord() *token {
+	start := s.pos
+	for {
+		r := s.peek(0)
+		if isWordRune(r) {
+			s.pos += utf8.RuneLen(r)
+			continue
+		}
+		// the dot of qualified name, .. is the range
+		if r == '.' && s.peek(1) != '.' && isWordRune(s.peek(1)) {
+			s.pos++
+			continue
+		}
+		break
+	}
+	word := s.src[start:s.pos]
+	if tokenType, ok := keywords[word]; ok {
+		return s.token(start, tokenType)
+	}
+	if word == "not" {
+		end := s.pos
+		s.skipSpace()
+		if s.pos > end && strings.HasPrefix(s.src[s.pos:], "in") && !isWordRune(s.peek(2)) {
+			s.pos += len("in")
+			return s.token(start, token_keyword_not_in)
+		}
+		s.pos = end
+	}
+	return s.token(start, token_symbol)
+}
+
+// scanNumber scans an integer or a float, eg. 3, -1, 2.5. A number followed by letters or another dot is invalid
+func (s *scanner) scanNumber() *token {
+	start := s.pos
+	if s.peek(0) == '-' {
+		s.pos++
+	}
+	s.skipDigits()
+	if s.peek(0) == '.' && isDigit(s.peek(1)) {
+		s.pos++
+		s.skipDigits()
+	}
+	if r := s.peek(0); isWordRune(r) || (r == '.' && s.peek(1) != '.') {
+		s.invalid(start, "invalid number %q")
+		return nil
+	}
+	return s.token(start, token_number)
+}
+
+func (s *scanner) skipDigits() {
+	for isDigit(s.peek(0)) {
+		s.pos++
+	}
+}
+
+// scanString scans a quoted string, the token text keeps the quotes and the escapes.
+// The escapes are \", \\, \n, \r, \t, \uXXXX and \UXXXXXXXX, the string with invalid escapes is still a token
+// after the escapes are reported, so that the parser goes on without more errors
+func (s *scanner) scanString() *token {
+	start := s.pos
+	s.pos++
+	for {
+		r := s.peek(0)
+		switch r {
+		case -1:
+			s.error(start, "string is not closed")
+			return nil
+		case '"':
+			s.pos++
+			return s.token(start, token_string)
+		case '\\':
+			s.scanEscape()
+		default:
+			s.pos += utf8.RuneLen(r)
+		}
+	}
+}
+
+// scanEscape scans the escape at pos, invalid escapes are reported and skipped
+func (s *scanner) scanEscape() {
+	start := s.pos
+	s.pos++
+	r := s.peek(0)
+	switch r {
+	case -1:
+	case '"', '\\', 'n', 'r', 't':
+		s.pos++
+	case 'u', 'U':
+		s.pos++
+		digits := 4
+		if r == 'U' {
+			digits = 8
+		}
+		for i := 0; i < digits; i++ {
+			if !isHexDigit(s.peek(0)) {
+				s.error(start, fmt.Sprintf("invalid escape %s in string, %d hex digits are expected", s.src[start:s.pos], digits))
+				return
+			}
+			s.pos++
+		}
+		if code, _ := strconv.ParseUint(s.src[start+2:s.pos], 16, 32); !utf8.ValidRune(rune(code)) {
+			s.error(start, fmt.Sprintf("invalid escape %s in string, it is not a valid unicode code point", s.src[start:s.pos]))
+		}
+	default:
+		s.pos += utf8.RuneLen(r)
+		s.error(start, fmt.Sprintf("invalid escape %s in string", s.src[start:s.pos]))
+	}
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+// tagPrefixLen returns the length of the tag at the start of s, 0 is returned when s does not start with the tag.
+// There can be any spaces between <if and cond=
+func tagPrefixLen(s string, tagType int) int {
+	text := token_texts[tagType]
+	if tagType == tag_if_start || tagType == tag_elif_start {
+		name := strings.TrimSuffix(text, " cond=")
+		if !strings.HasPrefix(s, name) {
+			return 0
+		}
+		rest := strings.TrimLeft(s[len(name):], " \t")
+		if len(rest) == len(s)-len(name) || !strings.HasPrefix(rest, "cond=") {
+			return 0
+		}
+		return len(s) - len(rest) + len("cond=")
+	}
+	if strings.HasPrefix(s, text) {
+		return len(text)
+	}
+	return 0
+}
+
+// findTag returns the byte offsets of the start and the end of the first tag in line, -1, -1 is returned when it is not found
+func findTag(line string, tagType int) (int, int) {
+	for i := 0; i < len(line); i++ {
+		next := strings.IndexByte(line[i:], '<')
+		if next < 0 {
+			break
+		}
+		i += next
+		if n := tagPrefixLen(line[i:], tagType); n > 0 {
+			return i, i + n
+		}
+	}
+	return -1, -1
+}
@@ -0,0 +1,114 @@
+package soscript
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testToken struct {
+	tokenType int
+	text      string
+	column    int
+}
+
+// scanTest lexes a line of def file, and returns its tokens and the diagnostics in the form of column: message
+func scanTest(line string) ([]testToken, []string) {
+	lexer := newLexer("ss", "t.ss", strings.NewReader(line))
+	var tokens []testToken
+	for _, v := range lexer.tokens {
+		tokens = append(tokens, testToken{v.tokenType, v.text, v.column})
+	}
+	var diagnostics []string
+	for _, v := range lexer.diagnostics {
+		diagnostics = append(diagnostics, fmt.Sprintf("%d: %s", v.Column, v.Message))
+	}
+	return tokens, diagnostics
+}
+
+func TestScanTokens(t *testing.T) {
+	tests := []struct {
+		line   string
+		tokens []testToken
+	}{
+		{`platform == "ios"`, []testToken{{token_symbol, "platform", 1}, {token_equal, "==", 10}, {token_string, `"ios"`, 13}}},
+		{`a!=b&&c||!d`, []testToken{
+			{token_symbol, "a", 1}, {token_not_equal, "!=", 2}, {token_symbol, "b", 4}, {token_keyword_and, "&&", 5},
+			{token_symbol, "c", 7}, {token_keyword_or, "||", 8}, {token_keyword_not, "!", 10}, {token_symbol, "d", 11},
+		}},
+		{`x:=y>=1<=2>3<4`, []testToken{
+			{token_symbol, "x", 1}, {token_define, ":=", 2}, {token_symbol, "y", 4}, {token_great_equal, ">=", 5},
+			{token_number, "1", 7}, {token_less_equal, "<=", 8}, {token_number, "2", 10}, {token_great, ">", 11},
+			{token_number, "3", 12}, {token_less, "<", 13}, {token_number, "4", 14},
+		}},
+		{`level: int[1..5]`, []testToken{
+			{token_symbol, "level", 1}, {token_colon, ":", 6}, {token_symbol, "int", 8}, {token_square_left, "[", 11},
+			{token_number, "1", 12}, {token_range, "..", 13}, {token_number, "5", 15}, {token_square_right, "]", 16},
+		}},
+		{`-1 2.5 -0.5`, []testToken{{token_number, "-1", 1}, {token_number, "2.5", 4}, {token_number, "-0.5", 8}}},
+		// keywords are whole words only
+		{`if ifMode elif else print printer in`, []testToken{
+			{token_keyword_if, "if", 1}, {token_symbol, "ifMode", 4}, {token_keyword_elif, "elif", 11},
+			{token_keyword_else, "else", 16}, {token_keyword_print, "print", 21}, {token_symbol, "printer", 27},
+			{token_keyword_in, "in", 35},
+		}},
+		// not is a keyword only in not in
+		{`a not  in (b, not)`, []testToken{
+			{token_symbol, "a", 1}, {token_keyword_not_in, "not  in", 3}, {token_brackets_left, "(", 11},
+			{token_symbol, "b", 12}, {token_comma, ",", 13}, {token_symbol, "not", 15}, {token_brackets_right, ")", 18},
+		}},
+		{`not inside`, []testToken{{token_symbol, "not", 1}, {token_symbol, "inside", 5}}},
+		{`common.platform _x1`, []testToken{{token_symbol, "common.platform", 1}, {token_symbol, "_x1", 17}}},
+		{`a = {"x", "y"} // comment "`, []testToken{
+			{token_symbol, "a", 1}, {token_assign, "=", 3}, {token_brace_left, "{", 5}, {token_string, `"x"`, 6},
+			{token_comma, ",", 9}, {token_string, `"y"`, 11}, {token_brace_right, "}", 14},
+		}},
+		{`"a\"b\\ \u00e9 \U0001F600" "平台"`, []testToken{{token_string, `"a\"b\\ \u00e9 \U0001F600"`, 1}, {token_string, `"平台"`, 28}}},
+		// the column is counted in bytes
+		{`"é" x`, []testToken{{token_string, `"é"`, 1}, {token_symbol, "x", 6}}},
+		{`<line>a</line>`, []testToken{{tag_line_start, "<line>", 1}, {token_symbol, "a", 7}, {tag_line_end, "</line>", 8}}},
+	}
+	for _, test := range tests {
+		tokens, diagnostics := scanTest(test.line)
+		if len(diagnostics) > 0 {
+			t.Errorf("%s: got diagnostics %v", test.line, diagnostics)
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("%s:\ngot  %v\nwant %v", test.line, tokens, test.tokens)
+		}
+	}
+}
+
+// the invalid tokens and escapes are reported at their columns, and the tokens after them are still scanned
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		line        string
+		tokens      []testToken
+		diagnostics []string
+	}{
+		{`a $ b`, []testToken{{token_symbol, "a", 1}, {token_symbol, "b", 5}}, []string{`3: invalid token "$"`}},
+		{`12ab c`, []testToken{{token_symbol, "c", 6}}, []string{`1: invalid number "12ab"`}},
+		{`1.2.3`, nil, []string{`1: invalid number "1.2.3"`}},
+		{`x = "a\qb"`, []testToken{{token_symbol, "x", 1}, {token_assign, "=", 3}, {token_string, `"a\qb"`, 5}},
+			[]string{`7: invalid escape \q in string`}},
+		{`"\u12g" "\x"`, []testToken{{token_string, `"\u12g"`, 1}, {token_string, `"\x"`, 9}},
+			[]string{`2: invalid escape \u12 in string, 4 hex digits are expected`, `10: invalid escape \x in string`}},
+		{`"\U0000FFF"`, []testToken{{token_string, `"\U0000FFF"`, 1}},
+			[]string{`2: invalid escape \U0000FFF in string, 8 hex digits are expected`}},
+		{`"\UFFFFFFFF" "\ud800"`, []testToken{{token_string, `"\UFFFFFFFF"`, 1}, {token_string, `"\ud800"`, 14}},
+			[]string{`2: invalid escape \UFFFFFFFF in string, it is not a valid unicode code point`,
+				`15: invalid escape \ud800 in string, it is not a valid unicode code point`}},
+		{`a = "abc`, []testToken{{token_symbol, "a", 1}, {token_assign, "=", 3}}, []string{`5: string is not closed`}},
+		{`"abc\`, nil, []string{`1: string is not closed`}},
+	}
+	for _, test := range tests {
+		tokens, diagnostics := scanTest(test.line)
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("%s:\ngot  %v\nwant %v", test.line, tokens, test.tokens)
+		}
+		if !reflect.DeepEqual(diagnostics, test.diagnostics) {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.line, diagnostics, test.diagnostics)
+		}
+	}
+}
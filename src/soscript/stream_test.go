@@ -0,0 +1,70 @@
+package soscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// the stream output is byte for byte the same as the output of compiling the whole source
+func TestStreamSameOutput(t *testing.T) {
+	many := strings.Repeat("line\n"+testSource+inPlaceSource, 50)
+	sources := map[string]string{
+		"soscript":       testSource,
+		"if block":       inPlaceSource,
+		"many blocks":    many,
+		"crlf":           strings.ReplaceAll(many, "\n", "\r\n"),
+		"no last break":  strings.TrimSuffix(many, "\n"),
+		"no blocks":      "a\nb\r\n\nc",
+		"empty":          "",
+		"block at start": inPlaceSource[strings.Index(inPlaceSource, "    // <if"):],
+		"var": `x
+// <soscript>
+// <default>
+int a = 0;
+// </default>
+// <line> if(platform == "android") print(<code>
+// int a = <var>level</var>;
+// int b = <var>platform|json</var>;
+// </code>) </line>
+// </soscript>
+y`,
+	}
+	for _, platform := range []string{"android", "ios", "h5"} {
+		cfg := loadTestConfig(t, testDefs, `platform = "`+platform+`"`)
+		for name, source := range sources {
+			for _, inPlace := range []bool{false, true} {
+				var out, streamOut bytes.Buffer
+				err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "A.java", InPlace: inPlace})
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				err = Compile(strings.NewReader(source), &streamOut, cfg, &Options{Name: "A.java", InPlace: inPlace, Stream: true})
+				if err != nil {
+					t.Fatalf("%s with stream: %v", name, err)
+				}
+				if streamOut.String() != out.String() {
+					t.Errorf("%s, %s, in-place %v: got stream output\n%q\nwant\n%q", name, platform, inPlace, streamOut.String(), out.String())
+				}
+			}
+		}
+	}
+}
+
+// the stream reports the same errors, but the blocks before the error are already written
+func TestStreamErrors(t *testing.T) {
+	source := testSource + strings.Replace(testSource, `"android"`, `"andriod"`, 1)
+	cfg := loadTestConfig(t, testDefs, `platform = "android"`)
+	for _, stream := range []bool{false, true} {
+		var out bytes.Buffer
+		err := Compile(strings.NewReader(source), &out, cfg, &Options{Name: "A.java", Stream: stream})
+		checkDiagnostics(t, err, []string{`A.java:11: value "andriod" is not declared for platform`})
+		want := ""
+		if stream {
+			want = "int a = 1;\n"
+		}
+		if out.String() != want {
+			t.Errorf("stream %v: got output %q, want %q", stream, out.String(), want)
+		}
+	}
+}
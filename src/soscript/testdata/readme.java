@@ -0,0 +1,17 @@
+// <soscript>
+// <default>
+let serverAddr = "http://localhost:8888"
+// </default>
+
+// <line> if(version == "1.0.1" || mode == "debug") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif(version == "1.0.1" && mode == "release") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif(version == "1.0.1") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+
+// <line> switch1 = (version == "1.0.1" && platform == "android") </line>
+// <line> switch2 = (version == "1.0.2" && platform == "ios") </line>
+// <line> switch3 = (version == "1.0.1" && platform == "h5") </line>
+// <line> elif (switch1) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif (switch2) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif (switch3) print(<code> let serverAddr = <var>addr|quoted</var> </code>) </line>
+
+// </soscript>
@@ -0,0 +1,82 @@
+package soscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version like 1.0.2 or 1.1.0-beta.1, missing numbers are treated as 0
+type version struct {
+	numbers    []int
+	prerelease []string
+}
+
+func parseVersion(text string) (*version, error) {
+	text = strings.TrimPrefix(text, "v")
+	// build metadata does not take part in ordering
+	if idx := strings.Index(text, "+"); idx >= 0 {
+		text = text[:idx]
+	}
+	ret := &version{}
+	if idx := strings.Index(text, "-"); idx >= 0 {
+		ret.prerelease = strings.Split(text[idx+1:], ".")
+		text = text[:idx]
+	}
+	for _, v := range strings.Split(text, ".") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", text)
+		}
+		ret.numbers = append(ret.numbers, n)
+	}
+	return ret, nil
+}
+
+func (v *version) compare(o *version) int {
+	for i := 0; i < len(v.numbers) || i < len(o.numbers); i++ {
+		a, b := 0, 0
+		if i < len(v.numbers) {
+			a = v.numbers[i]
+		}
+		if i < len(o.numbers) {
+			b = o.numbers[i]
+		}
+		if a != b {
+			return compareInt(a, b)
+		}
+	}
+	// a pre-release version is lower than the release version
+	if len(v.prerelease) == 0 || len(o.prerelease) == 0 {
+		return compareInt(len(o.prerelease), len(v.prerelease))
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		if a == b {
+			continue
+		}
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA == nil && errB == nil:
+			return compareInt(na, nb)
+		case errA == nil:
+			// numeric identifiers are lower than alphanumeric ones
+			return -1
+		case errB == nil:
+			return 1
+		}
+		return strings.Compare(a, b)
+	}
+	return compareInt(len(v.prerelease), len(o.prerelease))
+}
+
+func compareInt(a int, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package soscript
+
+import "testing"
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.1", "1.0.2", -1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.10", "1.9.9", 1},
+		{"1.0", "1.0.0", 0},
+		{"v1.2.0", "1.2", 0},
+		{"1.0.0+build.5", "1.0.0", 0},
+		// a pre-release version is lower than the release version
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-rc.1", "0.9.9", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0-rc.1", "1.0.0-rc.1", 0},
+	}
+	for _, test := range tests {
+		a, err := parseVersion(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseVersion(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.compare(b); got != test.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := b.compare(a); got != -test.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+	for _, text := range []string{"", "1.x", "1..2", "1.-2", "-beta"} {
+		if _, err := parseVersion(text); err == nil {
+			t.Errorf("parseVersion(%q) succeeds, want error", text)
+		}
+	}
+}
@@ -2,43 +2,138 @@ package main
 
 import (
 	"fmt"
+	"github.com/letmefly/soscript/src/soscript"
 	"github.com/urfave/cli"
 	"log"
 	"os"
+	"strings"
 )
 
-func compileOne(varDefFilePath string, varConfigFilePath string, sourceFilePath string, outputFilePath string) {
-	varDefFile, err := os.Open(varDefFilePath)
+// loadConfig loads the def file and the config layers: the config files in order, SSC_VAR_<name> environment variables and -D flags,
+// a later layer overrides the former ones. The config files are optional. importDirs are searched for the def files imported by the def file.
+// Only the file errors are returned, the errors in the def and config files are reported with the errors of the command
+func loadConfig(c *cli.Context) (*soscript.Config, error) {
+	defs, err := soscript.LoadDefsFile(c.String("variable"), c.StringSlice("import-dir")...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer varDefFile.Close()
-	varConfigFile, err := os.Open(varConfigFilePath)
-	if err != nil {
-		log.Fatal(err)
+	cfg := defs.NewConfig()
+	for _, v := range c.StringSlice("config") {
+		if err := cfg.LoadFile(v); err != nil {
+			return nil, err
+		}
 	}
-	defer varConfigFile.Close()
-	sourceFile, err := os.Open(sourceFilePath)
-	if err != nil {
-		log.Fatal(err)
+	cfg.LoadEnv(os.Environ())
+	cfg.Set(c.StringSlice("define")...)
+	return cfg, nil
+}
+
+// exitError prints the diagnostics of err to stderr, and returns the exit error of err
+func exitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	soscriptErr, ok := err.(*soscript.Error)
+	if !ok {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if len(soscriptErr.Diagnostics) > 0 {
+		lines := make([]string, 0, len(soscriptErr.Diagnostics))
+		for _, v := range soscriptErr.Diagnostics {
+			lines = append(lines, v.String())
+		}
+		fmt.Fprintln(os.Stderr, strings.Join(lines, "\n"))
+	}
+	if soscriptErr.Err != nil {
+		return cli.NewExitError(soscriptErr.Err.Error(), 1)
+	}
+	return cli.NewExitError(fmt.Sprintf("compile failed with %d error(s)", soscriptErr.ErrorCount()), 1)
+}
+
+// optionsFlag takes the options shared by the commands, the comment style is checked when it is used
+func optionsFlag(c *cli.Context) *soscript.Options {
+	return &soscript.Options{
+		Comment: c.String("comment"),
+		InPlace: c.Bool("in-place"),
+		Stream:  c.Bool("stream"),
+		Include: c.StringSlice("include"),
+		Exclude: c.StringSlice("exclude"),
+		Filter:  c.String("filter"),
+		Log:     os.Stdout,
+		ErrLog:  os.Stderr,
+	}
+}
+
+// sourcesFlag loads the file of --source or the files under --source-dir, one of them is required
+func sourcesFlag(c *cli.Context, outputDir string) (*soscript.Sources, error) {
+	sourceFilePath := c.String("source")
+	sourceDir := c.String("source-dir")
+	if (sourceFilePath == "") == (sourceDir == "") {
+		return nil, cli.NewExitError("one of --source and --source-dir is required", 1)
 	}
-	defer sourceFile.Close()
-	//outputFile, err := os.Open(outputFilePath)
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
-	//defer outputFile.Close()
-	varLexer := newLexer("ss", varDefFile)
-	configLexer := newLexer("ss", varConfigFile)
-	sourceLexer := newLexer("not_ss", sourceFile)
-	parser := newParser(varLexer, configLexer)
-	parser.parseSourceCode(sourceLexer)
-	generator := newServerGen(outputFilePath, parser)
-	generator.gen()
+	if sourceDir != "" {
+		return soscript.LoadSources(sourceDir, outputDir, optionsFlag(c))
+	}
+	return soscript.LoadSources(sourceFilePath, "", optionsFlag(c))
+}
+
+// def_flags load the def file, every command takes them
+var def_flags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "variable, v",
+		Usage: "Load Variable Definition File",
+	},
+	cli.StringSliceFlag{
+		Name:  "import-dir, I",
+		Usage: "Find The Def Files Imported By The Definition File In The Directory, Repeatable",
+	},
+}
+
+// config_flag is the --config of the commands that compile with one config, matrix and coverage describe it their own way
+var config_flag = cli.StringSliceFlag{
+	Name:  "config, c",
+	Usage: "Load Variable Config File, Repeatable, A Later File Overrides The Former Ones",
+}
+
+var define_flag = cli.StringSliceFlag{
+	Name:  "define, D",
+	Usage: "Assign Variable Over The Config File, eg. -D platform=android, Repeatable",
+}
+
+var source_flag = cli.StringFlag{
+	Name:  "source, s",
+	Usage: "Load Source File",
+}
+
+var source_dir_flag = cli.StringFlag{
+	Name:  "source-dir",
+	Usage: "Load All Source Files Under The Directory",
+}
+
+// pattern_flags select the files under --source-dir
+var pattern_flags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "include",
+		Usage: "Only Process Files Matching The Glob Pattern Of Source Directory",
+	},
+	cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "Skip Files And Directories Matching The Glob Pattern Of Source Directory",
+	},
 }
 
-func compileDir(varDefFilePath string, varConfigFilePath string, sourceDir string, outputDir string) {
+var comment_flag = cli.StringFlag{
+	Name:  "comment",
+	Usage: "Comment Syntax Of Source Files, A File Extension Like py Or Comment Marks Like \"<!-- -->\", Detected From File Extension By Default",
+}
 
+// joinFlags joins the flag groups of a command in order
+func joinFlags(groups ...[]cli.Flag) []cli.Flag {
+	var ret []cli.Flag
+	for _, v := range groups {
+		ret = append(ret, v...)
+	}
+	return ret
 }
 
 // go build . && ./ssc compile --variable def.ss --config wechat_conf.ss --source test.java --output output_test.java
@@ -49,82 +144,219 @@ func main() {
 			Name:    "compile",
 			Aliases: []string{"compile"},
 			Usage:   "Compile Source File",
-			Flags: []cli.Flag{
+			Flags: joinFlags(def_flags, []cli.Flag{config_flag, define_flag, source_flag,
 				cli.StringFlag{
-					Name: "variable, v",
-					Usage: "Load Variable Definition File",
+					Name:  "output, o",
+					Usage: "Store Compile Output File",
 				},
+				cli.BoolFlag{
+					Name:  "in-place, i",
+					Usage: "Rewrite Default Code Of Source File And Keep Soscript Tags",
+				},
+				source_dir_flag,
 				cli.StringFlag{
-					Name: "config, c",
-					Usage: "Load Variable Config File",
+					Name:  "output-dir",
+					Usage: "Store Compile Output Files With The Same Layout As Source Directory",
+				},
+			}, pattern_flags, []cli.Flag{comment_flag,
+				cli.BoolFlag{
+					Name:  "stream",
+					Usage: "Read Source Files Block By Block With Bounded Memory And Copy The Text Out Of Blocks Straight To The Output",
+				},
+			}),
+			Action: func(c *cli.Context) error {
+				sourceFilePath := c.String("s")
+				outputFilePath := c.String("o")
+				sourceDir := c.String("source-dir")
+				outputDir := c.String("output-dir")
+				inPlace := c.Bool("in-place")
+				if sourceDir != "" {
+					if inPlace {
+						if outputDir != "" {
+							return cli.NewExitError("--in-place can not be used with --output-dir", 1)
+						}
+						outputDir = sourceDir
+					}
+					if outputDir == "" {
+						return cli.NewExitError("--output-dir is required with --source-dir", 1)
+					}
+					cfg, err := loadConfig(c)
+					if err != nil {
+						return exitError(err)
+					}
+					summary, err := soscript.CompileDir(sourceDir, outputDir, cfg, optionsFlag(c))
+					// the files compiled before an error are written
+					if summary != nil {
+						for _, v := range summary.ChangedPaths {
+							log.Println("changed:", v)
+						}
+					}
+					if err != nil {
+						return exitError(err)
+					}
+					fmt.Println(summary)
+					return nil
+				}
+				if inPlace {
+					if outputFilePath != "" {
+						return cli.NewExitError("--in-place can not be used with --output", 1)
+					}
+					outputFilePath = sourceFilePath
+				}
+				cfg, err := loadConfig(c)
+				if err != nil {
+					return exitError(err)
+				}
+				return exitError(soscript.CompileFile(sourceFilePath, outputFilePath, cfg, optionsFlag(c)))
+			},
+		},
+		{
+			Name:  "matrix",
+			Usage: "Compile Source Files Once For Every Combination Of Variable Values",
+			Flags: joinFlags(def_flags, []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "config, c",
+					Usage: "Load Variable Config File, Repeatable, Variables Assigned In Them Are Not Part Of The Matrix",
 				},
+				define_flag, source_flag, source_dir_flag,
 				cli.StringFlag{
-					Name: "source, s",
-					Usage: "Load Source File",
+					Name:  "output-dir, o",
+					Usage: "Store Compile Output Of Each Combination Under <output-dir>/<combination>",
 				},
 				cli.StringFlag{
-					Name: "output, o",
-					Usage: "Store Compile Output File",
+					Name:  "filter, f",
+					Usage: "Only Compile The Combinations Matching The Condition, eg. 'platform != \"h5\"'",
 				},
+			}, pattern_flags, []cli.Flag{comment_flag}),
+			Action: func(c *cli.Context) error {
+				outputDir := c.String("output-dir")
+				if outputDir == "" {
+					return cli.NewExitError("--output-dir is required", 1)
+				}
+				sources, err := sourcesFlag(c, outputDir)
+				if err != nil {
+					return exitError(err)
+				}
+				cfg, err := loadConfig(c)
+				if err != nil {
+					return exitError(err)
+				}
+				summary, err := soscript.BuildMatrix(cfg, sources, outputDir, optionsFlag(c))
+				if summary != nil {
+					fmt.Println(summary)
+				}
+				return exitError(err)
 			},
-			Action:  func(c *cli.Context) error {
-				fmt.Println("haha")
-				fmt.Println(c.String("v"))
-				fmt.Println(c.String("c"))
-				fmt.Println(c.String("s"))
-				fmt.Println(c.String("o"))
-				varDefFilePath := c.String("v")
-				varConfigFilePath := c.String("c")
-				sourceFilePath := c.String("s")
-				outputFilePath := c.String("o")
-				compileOne(varDefFilePath, varConfigFilePath, sourceFilePath, outputFilePath)
+		},
+		{
+			Name:  "coverage",
+			Usage: "Report The Lines That Never Or Always Fire With All Combinations Of Variable Values",
+			Flags: joinFlags(def_flags, []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "config, c",
+					Usage: "Load Variable Config File, Repeatable, Variables Assigned In Them Are Not Part Of The Combinations",
+				},
+				define_flag, source_flag, source_dir_flag,
+				cli.StringFlag{
+					Name:  "filter, f",
+					Usage: "Only Analyze The Combinations Matching The Condition, eg. 'platform != \"h5\"'",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "table",
+					Usage: "Report Format, table Or json",
+				},
+			}, pattern_flags, []cli.Flag{comment_flag}),
+			Action: func(c *cli.Context) error {
+				format := c.String("format")
+				if format != "table" && format != "json" {
+					return cli.NewExitError(fmt.Sprintf("invalid --format %q, use table or json", format), 1)
+				}
+				sources, err := sourcesFlag(c, "")
+				if err != nil {
+					return exitError(err)
+				}
+				cfg, err := loadConfig(c)
+				if err != nil {
+					return exitError(err)
+				}
+				report, err := soscript.Coverage(cfg, sources, optionsFlag(c))
+				if report == nil {
+					return exitError(err)
+				}
+				if format == "json" {
+					if writeErr := report.WriteJSON(os.Stdout); writeErr != nil {
+						return writeErr
+					}
+				} else {
+					report.WriteTable(os.Stdout)
+				}
+				return exitError(err)
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "Print The Effective Config With Where Each Value Is Assigned",
+			Flags: joinFlags(def_flags, []cli.Flag{config_flag, define_flag}),
+			Action: func(c *cli.Context) error {
+				cfg, err := loadConfig(c)
+				if err != nil {
+					return exitError(err)
+				}
+				// the table is printed before the unassigned variables are reported
+				cfg.WriteTable(os.Stdout)
+				return exitError(cfg.Check())
+			},
+		},
+		{
+			Name:  "vars",
+			Usage: "List The Declared Variables With Their Values, Defaults And Descriptions",
+			Flags: def_flags,
+			Action: func(c *cli.Context) error {
+				defs, err := soscript.LoadDefsFile(c.String("variable"), c.StringSlice("import-dir")...)
+				if err != nil {
+					return exitError(err)
+				}
+				if err := defs.Err(); err != nil {
+					return exitError(err)
+				}
+				defs.WriteVars(os.Stdout)
 				return nil
 			},
 		},
+		{
+			Name:  "explain",
+			Usage: "Explain Why The Lines Of A Block Are Or Are Not Selected With The Config",
+			Flags: joinFlags(def_flags, []cli.Flag{config_flag, define_flag, source_flag,
+				cli.IntFlag{
+					Name:  "line, l",
+					Usage: "Line Number Of The <line> Or The Block To Explain",
+				},
+				comment_flag,
+			}),
+			Action: func(c *cli.Context) error {
+				sourceFilePath := c.String("source")
+				if sourceFilePath == "" || c.Int("line") <= 0 {
+					return cli.NewExitError("--source and --line are required", 1)
+				}
+				source, err := os.Open(sourceFilePath)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				defer source.Close()
+				cfg, err := loadConfig(c)
+				if err != nil {
+					return exitError(err)
+				}
+				opts := optionsFlag(c)
+				opts.Name = sourceFilePath
+				// the errors are reported after the explanation, which may tell why they happen
+				return exitError(soscript.Explain(os.Stdout, source, c.Int("line"), cfg, opts))
+			},
+		},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-
-
-	//srcRoot := "./"
-	//dirs, _ := ioutil.ReadDir(srcRoot + "pb")
-	//for _, dir := range dirs {
-	//	if dir.IsDir() {
-	//		files, _ := ioutil.ReadDir(srcRoot + "pb/" + dir.Name())
-	//		for _, file := range files {
-	//			if strings.HasSuffix(file.Name(), ".proto") {
-	//				//log.Println(file.Name())
-	//				strs := strings.Split(file.Name(), "_")
-	//				serverName := strs[0]
-	//				filePath := srcRoot + "pb/" + dir.Name() + "/" + file.Name()
-	//				log.Println(filePath)
-	//				protoFile, err := os.Open(filePath)
-	//				if err != nil {
-	//					log.Fatal(err)
-	//				}
-	//				lexer := newLexer(protoFile)
-	//				defer protoFile.Close()
-	//
-	//				parser := newParser(lexer)
-	//				parser.parse()
-	//				genType := "rpc"
-	//				genFile := ""
-	//				if strings.Contains(file.Name(), "_msg") {
-	//					genType = "msg"
-	//					genFile = srcRoot + "pb/" + dir.Name() + "/" + serverName + "_msg.gen.go"
-	//				} else if strings.Contains(file.Name(), "_rpc") {
-	//					genType = "rpc"
-	//					genFile = srcRoot + "pb/" + dir.Name() + "/" + serverName + "_rpc.gen.go"
-	//				}
-	//
-	//				log.Println("genFile:", genFile)
-	//				generator := newServerGen(genType, genFile, serverName, parser)
-	//				generator.gen()
-	//			}
-	//		}
-	//	}
-	//}
 }
@@ -4,14 +4,22 @@ String serverAddr = "http://localhost:8888";
 // </default>
 
 // <line> if((version == "1.0.1") && (mode == "release" || platform=="android")) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
-// <line> if(version == "1.0.1" && mode == "release") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
-// <line> if(version == "1.0.1") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif(version == "1.0.1" && mode == "release") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif(version == "1.0.1") print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
 
 // <line> switch1 = (version == "1.0.1" && platform == "android") </line>
 // <line> switch2 = (version == "1.0.2" && platform == "ios") </line>
-// <line> let switch3 = (version == "1.0.1" && platform == "wechat") </line>
-// <line> if (switch1) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
-// <line> if (switch2) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
-// <line> if (switch3) print(<code> let serverAddr = <var>addr</var> </code>) </line>
+// <line> let switch3 = (version == "1.0.1" && platform == "h5") </line>
+// <line> elif (switch1) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif (switch2) print(<code> let serverAddr = "http://localhost:8888" </code>) </line>
+// <line> elif (switch3) print(<code> let serverAddr = <var>addr|quoted</var> </code>) </line>
 
 // </soscript>
+
+// <soscript>
+// <default>
+Log.setLevel(0);
+// </default>
+// <line> if (enableLog && level > 3) print(<code> Log.setLevel(<var>level</var>); </code>) </line>
+// <line> elif (enableLog) print(<code> Log.setLevel(3); </code>) </line>
+// </soscript>